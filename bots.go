@@ -0,0 +1,88 @@
+package projectinfo
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// DefaultBotPatterns are regular expressions for the names and email addresses of common bots and automation accounts.
+// They are matched case-insensitively.
+var DefaultBotPatterns = []string{
+	`\[bot\]`,
+	`^bot@|-bot@|\bbot$`,
+	`^(dependabot|renovate|github-actions|greenkeeper|snyk-bot|pre-commit-ci|mergify|imgbot|allcontributors|codecov|semantic-release|web-flow|weblate|transifex)\b`,
+	`^(ci|jenkins|travis|travis-ci|circleci|buildkite|gitlab-ci|azure-pipelines|teamcity|buildbot)([ _-]?(bot|user|service|agent))?(@|$)`,
+	`^noreply@github\.com$`,
+}
+
+// BotDetector checks if contributors are bots or automation accounts, by matching their names and email addresses against patterns
+type BotDetector struct {
+	patterns []*regexp.Regexp
+}
+
+// defaultBotDetector uses DefaultBotPatterns
+var defaultBotDetector = mustBotDetector(DefaultBotPatterns)
+
+// mustBotDetector is like NewBotDetector, but panics if a pattern is invalid
+func mustBotDetector(patterns []string) *BotDetector {
+	detector, err := NewBotDetector(patterns, false)
+	if err != nil {
+		panic(err)
+	}
+	return detector
+}
+
+// NewBotDetector compiles the given regular expressions, which are matched case-insensitively against names and email addresses.
+// If useDefaults is true, DefaultBotPatterns are used as well.
+func NewBotDetector(patterns []string, useDefaults bool) (*BotDetector, error) {
+	if useDefaults {
+		patterns = append(append([]string{}, DefaultBotPatterns...), patterns...)
+	}
+	detector := &BotDetector{}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bot pattern %q: %v", pattern, err)
+		}
+		detector.patterns = append(detector.patterns, re)
+	}
+	return detector, nil
+}
+
+// IsBot checks if a name or one of the email addresses matches any of the patterns
+func (detector *BotDetector) IsBot(name string, emails ...string) bool {
+	for _, re := range detector.patterns {
+		if re.MatchString(name) {
+			return true
+		}
+		for _, email := range emails {
+			if re.MatchString(email) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsBot checks if a contributor with the given name and email addresses looks like a bot, using DefaultBotPatterns
+func IsBot(name string, emails ...string) bool {
+	return defaultBotDetector.IsBot(name, emails...)
+}
+
+// markBots sets the Bot field of the contributors that the detector recognizes as bots
+func markBots(contributors []Contributor, detector *BotDetector) {
+	for i := range contributors {
+		contributors[i].Bot = detector.IsBot(contributors[i].Name, contributors[i].Emails...)
+	}
+}
+
+// humanContributors returns the contributors that are not bots
+func humanContributors(contributors []Contributor) []Contributor {
+	var humans []Contributor
+	for _, contributor := range contributors {
+		if !contributor.Bot {
+			humans = append(humans, contributor)
+		}
+	}
+	return humans
+}
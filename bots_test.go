@@ -0,0 +1,70 @@
+package projectinfo
+
+import "testing"
+
+func TestIsBot(t *testing.T) {
+	testCases := []struct {
+		name  string
+		email string
+		want  bool
+	}{
+		{"dependabot[bot]", "49699333+dependabot[bot]@users.noreply.github.com", true},
+		{"renovate-bot", "bot@renovateapp.com", true},
+		{"github-actions", "41898282+github-actions[bot]@users.noreply.github.com", true},
+		{"GitHub", "noreply@github.com", true},
+		{"CI", "ci@example.com", true},
+		{"Jenkins Service", "jenkins@example.com", true},
+		{"Release Bot", "release@example.com", true},
+		{"Cindy", "cindy@example.com", false},
+		{"Ci Wang", "wang@example.com", false},
+		{"Talbot", "talbot@example.com", false},
+		{"Alexander", "1234+alexander@users.noreply.github.com", false},
+	}
+	for _, tc := range testCases {
+		if got := IsBot(tc.name, tc.email); got != tc.want {
+			t.Errorf("IsBot(%q, %q) = %v, want %v", tc.name, tc.email, got, tc.want)
+		}
+	}
+}
+
+func TestExcludeBots(t *testing.T) {
+	repo := newTestGitRepo(t)
+	c1 := repo.commit(repo.tree(map[string]gitHash{"a.go": repo.blob("package a\n")}), "Alice", 1000)
+	c2 := repo.commit(repo.tree(map[string]gitHash{"a.go": repo.blob("package a // 2\n")}), "dependabot[bot]", 2000, c1)
+	c3 := repo.commit(repo.tree(map[string]gitHash{"a.go": repo.blob("package a // 3\n")}), "dependabot[bot]", 3000, c2)
+	c4 := repo.commit(repo.tree(map[string]gitHash{"a.go": repo.blob("package a // 4\n")}), "Deploy Robot", 4000, c3)
+	repo.setRef("refs/heads/main", c4)
+
+	testCases := []struct {
+		opts     Options
+		summary  string
+		wantBots map[string]bool
+	}{
+		{Options{}, "dependabot[bot], Alice, Deploy Robot", map[string]bool{"dependabot[bot]": true}},
+		{Options{ExcludeBots: true}, "Alice, Deploy Robot", map[string]bool{"dependabot[bot]": true}},
+		{Options{ExcludeBots: true, BotPatterns: []string{`robot`}}, "Alice", map[string]bool{"dependabot[bot]": true, "Deploy Robot": true}},
+		{Options{ExcludeBots: true, BotPatterns: []string{`robot`}, NoDefaultBots: true}, "dependabot[bot], Alice", map[string]bool{"Deploy Robot": true}},
+	}
+	for _, tc := range testCases {
+		tc.opts.NoAPIServerCheck = true
+		project, err := NewWithOptions(repo.dir, tc.opts)
+		if err != nil {
+			t.Fatalf("NewWithOptions(%+v) error = %v", tc.opts, err)
+		}
+		if project.Contributors != tc.summary {
+			t.Errorf("Contributors with %+v = %q, want %q", tc.opts, project.Contributors, tc.summary)
+		}
+		if len(project.ContributorList) != 3 {
+			t.Errorf("ContributorList with %+v has %d contributors, want 3", tc.opts, len(project.ContributorList))
+		}
+		for _, contributor := range project.ContributorList {
+			if contributor.Bot != tc.wantBots[contributor.Name] {
+				t.Errorf("%s is marked as a bot = %v with %+v, want %v", contributor.Name, contributor.Bot, tc.opts, tc.wantBots[contributor.Name])
+			}
+		}
+	}
+
+	if _, err := NewWithOptions(repo.dir, Options{BotPatterns: []string{"("}}); err == nil {
+		t.Error("NewWithOptions() with an invalid bot pattern should fail")
+	}
+}
@@ -0,0 +1,282 @@
+package projectinfo
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// maxBPECacheSize is the number of pieces a BPETokenizer remembers the token count for, before the cache is cleared
+const maxBPECacheSize = 1 << 16
+
+// BPETokenizer is a byte-level Byte Pair Encoding tokenizer, as used by GPT-style language models
+type BPETokenizer struct {
+	ranks map[string]int // merge priority for each token, lower ranks are merged first
+	mut   sync.Mutex
+	cache map[string]int
+}
+
+// NewBPETokenizer creates a BPE tokenizer from a map of tokens (as raw bytes) to their merge ranks
+func NewBPETokenizer(ranks map[string]int) *BPETokenizer {
+	return &BPETokenizer{ranks: ranks, cache: make(map[string]int)}
+}
+
+// LoadBPETokenizer reads a BPE vocabulary from disk. Two formats are supported:
+// tiktoken rank files, where each line is a base64 encoded token followed by its rank,
+// and GPT-2 style merges.txt files, where each line is a pair of byte-level encoded tokens to merge.
+func LoadBPETokenizer(filename string) (*BPETokenizer, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#version") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("no BPE vocabulary found in %s", filename)
+	}
+	var ranks map[string]int
+	if isTiktokenLine(lines[0]) {
+		ranks, err = parseTiktokenRanks(lines)
+	} else {
+		ranks, err = parseBPEMerges(lines)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s: %v", filename, err)
+	}
+	return NewBPETokenizer(ranks), nil
+}
+
+// isTiktokenLine checks if the given line looks like a line from a tiktoken rank file
+func isTiktokenLine(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) != 2 {
+		return false
+	}
+	if _, err := strconv.Atoi(fields[1]); err != nil {
+		return false
+	}
+	_, err := base64.StdEncoding.DecodeString(fields[0])
+	return err == nil
+}
+
+// parseTiktokenRanks parses lines of base64 encoded tokens and their ranks
+func parseTiktokenRanks(lines []string) (map[string]int, error) {
+	ranks := make(map[string]int, len(lines))
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected a token and a rank", i+1)
+		}
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		ranks[string(token)] = rank
+	}
+	return ranks, nil
+}
+
+// parseBPEMerges parses lines of GPT-2 style merges, where the rank of a merged token is given by the line it first appears on
+func parseBPEMerges(lines []string) (map[string]int, error) {
+	decoder := byteLevelDecoder()
+	decode := func(s string) (string, error) {
+		var sb strings.Builder
+		for _, r := range s {
+			b, ok := decoder[r]
+			if !ok {
+				return "", fmt.Errorf("%q is not a byte-level encoded token", s)
+			}
+			sb.WriteByte(b)
+		}
+		return sb.String(), nil
+	}
+	ranks := make(map[string]int, len(lines)+256)
+	for i := 0; i < 256; i++ {
+		ranks[string([]byte{byte(i)})] = i
+	}
+	for i, line := range lines {
+		fields := strings.Split(line, " ")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected a pair of tokens", i+1)
+		}
+		left, err := decode(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		right, err := decode(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		if _, ok := ranks[left+right]; !ok {
+			ranks[left+right] = 256 + i
+		}
+	}
+	return ranks, nil
+}
+
+// byteLevelDecoder returns the mapping from the printable runes used in GPT-2 style vocabularies back to the bytes they represent
+func byteLevelDecoder() map[rune]byte {
+	decoder := make(map[rune]byte, 256)
+	n := 0
+	for b := 0; b < 256; b++ {
+		if (b >= '!' && b <= '~') || (b >= 0xA1 && b <= 0xAC) || (b >= 0xAE && b <= 0xFF) {
+			decoder[rune(b)] = byte(b)
+		} else {
+			decoder[rune(256+n)] = byte(b)
+			n++
+		}
+	}
+	return decoder
+}
+
+// CountTokens counts the number of BPE tokens in a string
+func (t *BPETokenizer) CountTokens(input string) int {
+	count := 0
+	for _, piece := range splitPieces(input) {
+		count += t.pieceTokens(piece)
+	}
+	return count
+}
+
+// pieceTokens counts the number of tokens a single pre-tokenized piece is encoded as
+func (t *BPETokenizer) pieceTokens(piece string) int {
+	if _, ok := t.ranks[piece]; ok {
+		return 1
+	}
+	t.mut.Lock()
+	count, ok := t.cache[piece]
+	t.mut.Unlock()
+	if ok {
+		return count
+	}
+
+	// Start with single bytes, then keep merging the adjacent pair with the lowest rank
+	parts := make([]string, len(piece))
+	for i := range parts {
+		parts[i] = piece[i : i+1]
+	}
+	for len(parts) > 1 {
+		bestIndex, bestRank := -1, 0
+		for i := 0; i < len(parts)-1; i++ {
+			if rank, ok := t.ranks[parts[i]+parts[i+1]]; ok && (bestIndex < 0 || rank < bestRank) {
+				bestIndex, bestRank = i, rank
+			}
+		}
+		if bestIndex < 0 {
+			break
+		}
+		parts[bestIndex] += parts[bestIndex+1]
+		parts = append(parts[:bestIndex+1], parts[bestIndex+2:]...)
+	}
+	count = len(parts)
+
+	t.mut.Lock()
+	if len(t.cache) >= maxBPECacheSize {
+		t.cache = make(map[string]int)
+	}
+	t.cache[piece] = count
+	t.mut.Unlock()
+	return count
+}
+
+// isNewline checks if the given rune is a carriage return or a line feed
+func isNewline(r rune) bool {
+	return r == '\r' || r == '\n'
+}
+
+// isPunctuation checks if the given rune is neither whitespace, a letter nor a number
+func isPunctuation(r rune) bool {
+	return !unicode.IsSpace(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// contractionLength returns the length of an English contraction like 's or 'll at the start of rs, or 0
+func contractionLength(rs []rune) int {
+	if len(rs) < 2 || rs[0] != '\'' {
+		return 0
+	}
+	for _, suffix := range []string{"ll", "re", "ve", "s", "t", "m", "d"} {
+		if len(rs) > len(suffix) && strings.EqualFold(string(rs[1:1+len(suffix)]), suffix) {
+			return 1 + len(suffix)
+		}
+	}
+	return 0
+}
+
+// splitPieces splits a string into the pieces that BPE is applied to, in the same way as the pre-tokenization of GPT-4 style tokenizers
+func splitPieces(s string) []string {
+	var (
+		rs     = []rune(s)
+		n      = len(rs)
+		pieces []string
+	)
+	for i := 0; i < n; {
+		start := i
+		r := rs[i]
+		switch {
+		case contractionLength(rs[i:]) > 0:
+			i += contractionLength(rs[i:])
+		case unicode.IsLetter(r) || (!isNewline(r) && !unicode.IsNumber(r) && i+1 < n && unicode.IsLetter(rs[i+1])):
+			// An optional leading space or symbol, followed by letters
+			i++
+			for i < n && unicode.IsLetter(rs[i]) {
+				i++
+			}
+		case unicode.IsNumber(r):
+			// Numbers are split into groups of at most three digits
+			for i < n && i-start < 3 && unicode.IsNumber(rs[i]) {
+				i++
+			}
+		case !unicode.IsSpace(r) || (r == ' ' && i+1 < n && isPunctuation(rs[i+1])):
+			// An optional leading space, followed by punctuation and any trailing newlines
+			if r == ' ' {
+				i++
+			}
+			for i < n && isPunctuation(rs[i]) {
+				i++
+			}
+			for i < n && isNewline(rs[i]) {
+				i++
+			}
+		default:
+			end := i
+			lastNewline := -1
+			for end < n && unicode.IsSpace(rs[end]) {
+				if isNewline(rs[end]) {
+					lastNewline = end
+				}
+				end++
+			}
+			switch {
+			case lastNewline >= 0:
+				i = lastNewline + 1
+			case end < n && end-i > 1:
+				// Leave the last space, so that it can be attached to the next word
+				i = end - 1
+			default:
+				i = end
+			}
+		}
+		pieces = append(pieces, string(rs[start:i]))
+	}
+	return pieces
+}
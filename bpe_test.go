@@ -0,0 +1,66 @@
+package projectinfo
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitPieces(t *testing.T) {
+	got := splitPieces("Hello world, it's 12345!\n\n  x")
+	want := []string{"Hello", " world", ",", " it", "'s", " ", "123", "45", "!\n\n", " ", " x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitPieces() = %q, want %q", got, want)
+	}
+}
+
+func TestLoadBPETokenizer(t *testing.T) {
+	tempDir := t.TempDir()
+
+	var tiktoken strings.Builder
+	for i, token := range []string{"h", "e", "l", "o", "he", "ll", "hell", "hello", " ", " w"} {
+		fmt.Fprintf(&tiktoken, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), i)
+	}
+	merges := "#version: 0.2\nh e\nl l\nhe ll\nhell o\nĠ w\n"
+
+	testCases := []struct {
+		name     string
+		filename string
+		content  string
+	}{
+		{name: "tiktoken ranks", filename: "vocab.tiktoken", content: tiktoken.String()},
+		{name: "merges.txt", filename: "merges.txt", content: merges},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(tempDir, tc.filename)
+			if err := os.WriteFile(path, []byte(tc.content), 0644); err != nil {
+				t.Fatalf("Failed to write vocabulary: %v", err)
+			}
+			bpe, err := LoadBPETokenizer(path)
+			if err != nil {
+				t.Fatalf("LoadBPETokenizer() error = %v", err)
+			}
+			// "hello" is one token, " w" is one token, and "x" and "y" are one byte each
+			if got := bpe.CountTokens("hello wxy"); got != 4 {
+				t.Errorf("CountTokens() = %d, want 4", got)
+			}
+		})
+	}
+}
+
+func TestSetTokenizer(t *testing.T) {
+	defer SetTokenizer(nil)
+	SetTokenizer(NewBPETokenizer(map[string]int{}))
+	if got := CountTokens("abc"); got != 3 {
+		t.Errorf("CountTokens() with an empty vocabulary = %d, want 3", got)
+	}
+	SetTokenizer(nil)
+	if got := CountTokens("abcdefgh"); got != 2 {
+		t.Errorf("CountTokens() with the default tokenizer = %d, want 2", got)
+	}
+}
@@ -0,0 +1,226 @@
+package projectinfo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// DefaultMaxTokensPerChunk is the default token budget for a single chunk of JSON
+const DefaultMaxTokensPerChunk = 4096
+
+var maxTokensPerChunk = DefaultMaxTokensPerChunk
+
+// SetMaxTokensPerChunk sets the maximum number of tokens a chunk produced by Chunk may contain
+func SetMaxTokensPerChunk(maxTokens int) {
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxTokensPerChunk
+	}
+	maxTokensPerChunk = maxTokens
+}
+
+// MaxTokensPerChunk returns the current maximum number of tokens per chunk
+func MaxTokensPerChunk() int {
+	return maxTokensPerChunk
+}
+
+// ProjectChunk is one part of a ProjectInfo, small enough to fit within the token budget.
+// The project metadata is repeated in every chunk.
+type ProjectChunk struct {
+	Name            string     `json:"name"`
+	RepoURL         string     `json:"repositoryURL"`
+	Type            string     `json:"type"`
+	Contributors    string     `json:"contributors"`
+	APIServer       bool       `json:"apiServer"`
+	Chunk           int        `json:"chunk"`
+	TotalChunks     int        `json:"totalChunks"`
+	SourceFiles     []FileInfo `json:"sourceFiles,omitempty"`
+	ConfAndDocFiles []FileInfo `json:"confAndDocFiles,omitempty"`
+}
+
+// chunkBudget returns the maximum number of tokens per chunk and the tokenizer to count them with,
+// as given by the options the project was created with, or by SetMaxTokensPerChunk and SetTokenizer
+func (project *ProjectInfo) chunkBudget() (int, Tokenizer) {
+	maxTokens, t := maxTokensPerChunk, tokenizer
+	if project.maxTokensPerChunk > 0 {
+		maxTokens = project.maxTokensPerChunk
+	}
+	if project.tokenizer != nil {
+		t = project.tokenizer
+	}
+	return maxTokens, t
+}
+
+// languageRegistry returns the languages that were given in the options the project was created with, or DefaultLanguages
+func (project *ProjectInfo) languageRegistry() *LanguageRegistry {
+	if project.languages != nil {
+		return project.languages
+	}
+	return DefaultLanguages
+}
+
+// newChunk returns an empty chunk that only contains the project metadata
+func (project *ProjectInfo) newChunk() ProjectChunk {
+	return ProjectChunk{
+		Name:         project.Name,
+		RepoURL:      project.RepoURL,
+		Type:         project.Type,
+		Contributors: project.Contributors,
+		APIServer:    project.APIServer,
+	}
+}
+
+// marshalJSON encodes the given value as compact JSON, without escaping HTML characters
+func marshalJSON(v interface{}) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return string(bytes.TrimRight(buf.Bytes(), "\n")), nil
+}
+
+// jsonTokens returns the number of tokens needed for the JSON representation of the given value
+func jsonTokens(t Tokenizer, v interface{}) (int, error) {
+	s, err := marshalJSON(v)
+	if err != nil {
+		return 0, err
+	}
+	return t.CountTokens(s), nil
+}
+
+// chunkEntry is a file that is about to be placed in a chunk, together with its estimated token cost
+type chunkEntry struct {
+	file   FileInfo
+	source bool
+	tokens int
+}
+
+// Chunk splits the project information into JSON strings that each stay within the maximum number of tokens per chunk.
+// The token budget and tokenizer from Options are used if they were given to NewWithOptions.
+// The project metadata is included in every chunk, while the source files and/or the configuration and documentation files are spread out over the chunks.
+// Files that are too large for a single chunk are split into parts with SplitFile.
+func (project *ProjectInfo) Chunk(alsoSourceFiles, alsoConfAndDocFiles bool) ([]string, error) {
+	maxTokens, t := project.chunkBudget()
+
+	// Measure the metadata with large chunk numbers, so that the final numbering never pushes a chunk over the limit
+	base := project.newChunk()
+	base.Chunk, base.TotalChunks = math.MaxInt32, math.MaxInt32
+	baseTokens, err := jsonTokens(t, base)
+	if err != nil {
+		return nil, err
+	}
+	// Reserve room for the two array keys, in case both kinds of files end up in the same chunk
+	baseTokens += t.CountTokens(`,"sourceFiles":[],"confAndDocFiles":[]`)
+	if baseTokens > maxTokens {
+		return nil, fmt.Errorf("the project metadata alone needs %d tokens, which is more than the maximum of %d tokens per chunk", baseTokens, maxTokens)
+	}
+
+	var entries []chunkEntry
+	addEntries := func(files []FileInfo, source bool) error {
+		for _, file := range files {
+			tokens, err := jsonTokens(t, file)
+			if err != nil {
+				return err
+			}
+			// One extra token for the separating comma
+			if baseTokens+tokens+1 <= maxTokens {
+				entries = append(entries, chunkEntry{file: file, source: source, tokens: tokens + 1})
+				continue
+			}
+			// The file is too large for a single chunk, so split it into parts
+			parts, err := splitFile(t, project.languageRegistry(), file, maxTokens-baseTokens)
+			if err != nil {
+				return err
+			}
+			for _, part := range parts {
+				tokens, err := jsonTokens(t, part)
+				if err != nil {
+					return err
+				}
+				entries = append(entries, chunkEntry{file: part, source: source, tokens: tokens + 1})
+			}
+		}
+		return nil
+	}
+	if alsoSourceFiles {
+		if err := addEntries(project.SourceFiles, true); err != nil {
+			return nil, err
+		}
+	}
+	if alsoConfAndDocFiles {
+		if err := addEntries(project.ConfAndDocFiles, false); err != nil {
+			return nil, err
+		}
+	}
+
+	// Pack the files greedily, in order, by their estimated token costs
+	var (
+		groups        [][]chunkEntry
+		current       []chunkEntry
+		currentTokens = baseTokens
+	)
+	for _, entry := range entries {
+		if len(current) > 0 && currentTokens+entry.tokens > maxTokens {
+			groups = append(groups, current)
+			current = nil
+			currentTokens = baseTokens
+		}
+		current = append(current, entry)
+		currentTokens += entry.tokens
+	}
+	groups = append(groups, current)
+
+	return project.renderChunks(t, groups, maxTokens)
+}
+
+// chunk returns a chunk with the project metadata and the files of the given entries
+func (project *ProjectInfo) chunk(group []chunkEntry) ProjectChunk {
+	chunk := project.newChunk()
+	for _, entry := range group {
+		if entry.source {
+			chunk.SourceFiles = append(chunk.SourceFiles, entry.file)
+		} else {
+			chunk.ConfAndDocFiles = append(chunk.ConfAndDocFiles, entry.file)
+		}
+	}
+	return chunk
+}
+
+// renderChunks numbers the chunks with the files of the given groups and encodes them as JSON.
+// The token costs of the entries are only estimates, so a chunk that turns out to be over maxTokens gives its last entry to a new chunk after it,
+// until every chunk fits. Only a single entry that is over maxTokens on its own is an error.
+func (project *ProjectInfo) renderChunks(t Tokenizer, groups [][]chunkEntry, maxTokens int) ([]string, error) {
+	for {
+		result := make([]string, 0, len(groups))
+		total := len(groups)
+		for i := 0; i < len(groups); i++ {
+			chunk := project.chunk(groups[i])
+			chunk.Chunk, chunk.TotalChunks = i+1, total
+			s, err := marshalJSON(chunk)
+			if err != nil {
+				return nil, err
+			}
+			tokens := t.CountTokens(s)
+			if tokens <= maxTokens {
+				result = append(result, s)
+				continue
+			}
+			group := groups[i]
+			if len(group) < 2 {
+				return nil, fmt.Errorf("chunk %d of %d needs %d tokens, which is more than the maximum of %d tokens per chunk", i+1, total, tokens, maxTokens)
+			}
+			// Move the last entry to a new chunk, and render this chunk again
+			last := group[len(group)-1]
+			groups[i] = group[:len(group)-1]
+			groups = append(groups[:i+1], append([][]chunkEntry{{last}}, groups[i+1:]...)...)
+			i--
+		}
+		if len(groups) == total {
+			return result, nil
+		}
+		// The total number of chunks changed, so the chunks are numbered and rendered again
+	}
+}
@@ -0,0 +1,110 @@
+package projectinfo
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestChunk(t *testing.T) {
+	defer SetMaxTokensPerChunk(DefaultMaxTokensPerChunk)
+
+	project := ProjectInfo{
+		Name:         "example",
+		RepoURL:      "https://github.com/example/example",
+		Type:         "Go",
+		Contributors: "Alice, Bob",
+	}
+	for i := 0; i < 20; i++ {
+		project.SourceFiles = append(project.SourceFiles, FileInfo{
+			Path:     "main.go",
+			Language: "Go",
+			Contents: strings.Repeat("x", 200),
+		})
+	}
+	project.ConfAndDocFiles = []FileInfo{{Path: "README.md", Language: "Markdown", Contents: "# example"}}
+
+	const maxTokens = 400
+	SetMaxTokensPerChunk(maxTokens)
+
+	chunks, err := project.Chunk(true, true)
+	if err != nil {
+		t.Fatalf("Chunk() error = %v", err)
+	}
+	if len(chunks) < 2 {
+		t.Fatalf("Chunk() returned %d chunks, want at least 2", len(chunks))
+	}
+
+	var sourceFiles, confAndDocFiles int
+	for i, s := range chunks {
+		if tokens := CountTokens(s); tokens > maxTokens {
+			t.Errorf("chunk %d has %d tokens, want at most %d", i+1, tokens, maxTokens)
+		}
+		var chunk ProjectChunk
+		if err := json.Unmarshal([]byte(s), &chunk); err != nil {
+			t.Fatalf("chunk %d is not valid JSON: %v", i+1, err)
+		}
+		if chunk.Name != project.Name || chunk.RepoURL != project.RepoURL || chunk.Contributors != project.Contributors {
+			t.Errorf("chunk %d is missing the project metadata: %+v", i+1, chunk)
+		}
+		if chunk.Chunk != i+1 || chunk.TotalChunks != len(chunks) {
+			t.Errorf("chunk %d is numbered %d of %d, want %d of %d", i+1, chunk.Chunk, chunk.TotalChunks, i+1, len(chunks))
+		}
+		sourceFiles += len(chunk.SourceFiles)
+		confAndDocFiles += len(chunk.ConfAndDocFiles)
+	}
+	if sourceFiles != len(project.SourceFiles) || confAndDocFiles != len(project.ConfAndDocFiles) {
+		t.Errorf("got %d source and %d doc files, want %d and %d", sourceFiles, confAndDocFiles, len(project.SourceFiles), len(project.ConfAndDocFiles))
+	}
+
+	chunks, err = project.Chunk(false, true)
+	if err != nil {
+		t.Fatalf("Chunk() error = %v", err)
+	}
+	if len(chunks) != 1 {
+		t.Errorf("Chunk(false, true) returned %d chunks, want 1", len(chunks))
+	}
+}
+
+// crowdedTokenizer counts more tokens for a string with many files than for the files on their own,
+// like a BPE tokenizer may do when tokens are merged across the boundaries of the JSON entries
+type crowdedTokenizer struct{}
+
+func (crowdedTokenizer) CountTokens(input string) int {
+	files := strings.Count(input, `"path":`)
+	return HeuristicTokenizer{}.CountTokens(input) + files*files*10
+}
+
+func TestChunkRepacksOverflow(t *testing.T) {
+	project := ProjectInfo{Name: "example", Type: "Go", tokenizer: crowdedTokenizer{}, maxTokensPerChunk: 400}
+	for i := 0; i < 20; i++ {
+		project.SourceFiles = append(project.SourceFiles, FileInfo{Path: "main.go", Language: "Go", Contents: strings.Repeat("x", 100)})
+	}
+	chunks, err := project.Chunk(true, false)
+	if err != nil {
+		t.Fatalf("Chunk() error = %v", err)
+	}
+	var sourceFiles int
+	for i, s := range chunks {
+		if tokens := (crowdedTokenizer{}).CountTokens(s); tokens > 400 {
+			t.Errorf("chunk %d has %d tokens, want at most 400", i+1, tokens)
+		}
+		var chunk ProjectChunk
+		if err := json.Unmarshal([]byte(s), &chunk); err != nil {
+			t.Fatalf("chunk %d is not valid JSON: %v", i+1, err)
+		}
+		if chunk.Chunk != i+1 || chunk.TotalChunks != len(chunks) {
+			t.Errorf("chunk %d is numbered %d of %d, want %d of %d", i+1, chunk.Chunk, chunk.TotalChunks, i+1, len(chunks))
+		}
+		sourceFiles += len(chunk.SourceFiles)
+	}
+	if sourceFiles != len(project.SourceFiles) {
+		t.Errorf("got %d source files, want %d", sourceFiles, len(project.SourceFiles))
+	}
+
+	// A single file that is over the budget, even after splitting, is an error
+	project.maxTokensPerChunk = 60
+	if _, err := project.Chunk(true, false); err == nil {
+		t.Error("Chunk() with a budget that no file fits in returned no error")
+	}
+}
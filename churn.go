@@ -0,0 +1,121 @@
+package projectinfo
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// DefaultMaxHotspots is the number of hotspots that are listed if Options.MaxHotspots is 0
+const DefaultMaxHotspots = 20
+
+// FileChurn describes how often a file has changed, within the time window given by Options.ChurnSince
+type FileChurn struct {
+	Commits      int       `json:"commits"`
+	LinesAdded   int       `json:"lines_added,omitempty"`   // only counted with Options.LineStats
+	LinesRemoved int       `json:"lines_removed,omitempty"` // only counted with Options.LineStats
+	Authors      int       `json:"authors"`                 // the number of distinct authors
+	LastChanged  time.Time `json:"last_changed"`
+}
+
+// LinesChanged returns the number of lines that were added or removed
+func (churn FileChurn) LinesChanged() int {
+	return churn.LinesAdded + churn.LinesRemoved
+}
+
+// Hotspot is a file that changes often and is large, which makes it a likely place for bugs
+type Hotspot struct {
+	Path         string  `json:"path"`
+	Commits      int     `json:"commits"`
+	LinesChanged int     `json:"linesChanged"`
+	Authors      int     `json:"authors"`
+	LineCount    int     `json:"lineCount"`
+	Score        float64 `json:"score"` // the number of commits times the number of lines, relative to the highest score in the project
+}
+
+// churn counts the commits, authors and, if lineStats is true, changed lines for a file, for the commits that were authored after since.
+// A zero since counts all commits.
+func (history *gitHistory) churn(relPath string, since time.Time, lineStats bool) FileChurn {
+	var churn FileChurn
+	authors := make(map[string]bool)
+	for _, i := range history.byPath[relPath] {
+		commit := history.commits[i]
+		if !since.IsZero() && commit.author.When.Before(since) {
+			continue
+		}
+		churn.Commits++
+		authors[history.identity(commit.author).Name] = true
+		if commit.author.When.After(churn.LastChanged) {
+			churn.LastChanged = commit.author.When
+		}
+		if !lineStats {
+			continue
+		}
+		history.repo.countLines(commit)
+		for _, change := range commit.changes {
+			if change.path == relPath {
+				churn.LinesAdded += change.added
+				churn.LinesRemoved += change.removed
+			}
+		}
+	}
+	churn.Authors = len(authors)
+	return churn
+}
+
+// churnForFile returns the churn of a file, using the given histories, or nil if the file is not in a git repository
+// or has no commits in the time window, like an untracked file
+func churnForFile(histories *gitHistories, path string, since time.Time, lineStats bool) *FileChurn {
+	history, relPath, err := histories.forPath(path)
+	if err != nil {
+		return nil
+	}
+	churn := history.churn(relPath, since, lineStats)
+	if churn.Commits == 0 {
+		return nil
+	}
+	return &churn
+}
+
+// Hotspots ranks the files that have churn information by how often they changed times how many lines they have,
+// and returns the top max files. Files without commits in the time window are left out.
+func Hotspots(files []FileInfo, max int) []Hotspot {
+	var hotspots []Hotspot
+	var highest float64
+	for _, file := range files {
+		if file.Churn == nil || file.Churn.Commits == 0 {
+			continue
+		}
+		hotspot := Hotspot{
+			Path:         file.Path,
+			Commits:      file.Churn.Commits,
+			LinesChanged: file.Churn.LinesChanged(),
+			Authors:      file.Churn.Authors,
+			LineCount:    file.LineCount,
+			Score:        float64(file.Churn.Commits * file.LineCount),
+		}
+		if hotspot.Score > highest {
+			highest = hotspot.Score
+		}
+		hotspots = append(hotspots, hotspot)
+	}
+	for i := range hotspots {
+		if highest > 0 {
+			hotspots[i].Score = math.Round(1000*hotspots[i].Score/highest) / 1000
+		}
+	}
+	sort.SliceStable(hotspots, func(i, j int) bool {
+		a, b := hotspots[i], hotspots[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Commits != b.Commits {
+			return a.Commits > b.Commits
+		}
+		return a.Path < b.Path
+	})
+	if max > 0 && len(hotspots) > max {
+		hotspots = hotspots[:max]
+	}
+	return hotspots
+}
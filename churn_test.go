@@ -0,0 +1,91 @@
+package projectinfo
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChurnAndHotspots(t *testing.T) {
+	repo := newTestGitRepo(t)
+	a1, a2, a3 := "package a\n", "package a\n\nfunc A() {}\n", "package a\n\nfunc A() int { return 1 }\n"
+	b1 := "package a\n\nfunc B() {}\n\nfunc C() {}\n"
+	c1 := repo.commit(repo.tree(map[string]gitHash{"a.go": repo.blob(a1), "b.go": repo.blob(b1)}), "Alice", 1000)
+	c2 := repo.commit(repo.tree(map[string]gitHash{"a.go": repo.blob(a2), "b.go": repo.blob(b1)}), "Bob", 2000, c1)
+	c3 := repo.commit(repo.tree(map[string]gitHash{"a.go": repo.blob(a3), "b.go": repo.blob(b1)}), "Alice", 3000, c2)
+	repo.setRef("refs/heads/main", c3)
+	repo.writeFile("a.go", a3)
+	repo.writeFile("b.go", b1)
+
+	testCases := []struct {
+		since        time.Time
+		wantA        FileChurn
+		wantHotspots []string
+	}{
+		{time.Time{}, FileChurn{Commits: 3, LinesAdded: 4, LinesRemoved: 1, Authors: 2, LastChanged: time.Unix(3000, 0)}, []string{"a.go", "b.go"}},
+		{time.Unix(1500, 0), FileChurn{Commits: 2, LinesAdded: 3, LinesRemoved: 1, Authors: 2, LastChanged: time.Unix(3000, 0)}, []string{"a.go"}},
+		{time.Unix(5000, 0), FileChurn{}, nil},
+	}
+	for _, tc := range testCases {
+		project, err := NewWithOptions(repo.dir, Options{ChurnSince: tc.since, LineStats: true, NoAPIServerCheck: true})
+		if err != nil {
+			t.Fatalf("NewWithOptions() error = %v", err)
+		}
+		a := FindFileName(project.SourceFiles, "a.go")
+		if tc.wantA.Commits == 0 {
+			if a.Churn != nil {
+				t.Errorf("churn of a.go since %v = %+v, want none without commits", tc.since, *a.Churn)
+			}
+		} else if a.Churn == nil {
+			t.Fatalf("a.go has no churn")
+		}
+		var got FileChurn
+		if a.Churn != nil {
+			got = *a.Churn
+		}
+		if got.Commits != tc.wantA.Commits || got.LinesAdded != tc.wantA.LinesAdded || got.LinesRemoved != tc.wantA.LinesRemoved ||
+			got.Authors != tc.wantA.Authors || !got.LastChanged.Equal(tc.wantA.LastChanged) {
+			t.Errorf("churn of a.go since %v = %+v, want %+v", tc.since, got, tc.wantA)
+		}
+		var paths []string
+		for _, hotspot := range project.Hotspots {
+			paths = append(paths, filepath.Base(hotspot.Path))
+		}
+		if len(paths) != len(tc.wantHotspots) {
+			t.Fatalf("hotspots since %v = %v, want %v", tc.since, paths, tc.wantHotspots)
+		}
+		for i := range paths {
+			if paths[i] != tc.wantHotspots[i] {
+				t.Errorf("hotspots since %v = %v, want %v", tc.since, paths, tc.wantHotspots)
+				break
+			}
+		}
+		if len(project.Hotspots) > 0 && project.Hotspots[0].Score != 1 {
+			t.Errorf("the score of the top hotspot = %v, want 1", project.Hotspots[0].Score)
+		}
+	}
+
+	// Without LineStats, only the cheap counts are filled in, and an untracked file has no churn
+	repo.writeFile("untracked.go", "package a\n")
+	project, err := NewWithOptions(repo.dir, Options{NoAPIServerCheck: true})
+	if err != nil {
+		t.Fatalf("NewWithOptions() error = %v", err)
+	}
+	if churn := FindFileName(project.SourceFiles, "a.go").Churn; churn == nil || churn.Commits != 3 || churn.LinesChanged() != 0 {
+		t.Errorf("churn of a.go without LineStats = %+v, want 3 commits and no line counts", churn)
+	}
+	if untracked := FindFileName(project.SourceFiles, "untracked.go"); untracked.Path == "" || untracked.Churn != nil {
+		t.Errorf("untracked file = %+v, want it without churn", untracked)
+	}
+	for _, contributor := range project.ContributorList {
+		if contributor.Commits == 0 || contributor.LinesAdded != 0 || contributor.LinesRemoved != 0 {
+			t.Errorf("contributor without LineStats = %+v", contributor)
+		}
+		// Line counts that were not computed are left out, instead of being written as 0
+		if data, err := json.Marshal(contributor); err != nil || strings.Contains(string(data), "lines") {
+			t.Errorf("JSON of a contributor without LineStats = %s, %v", data, err)
+		}
+	}
+}
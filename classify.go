@@ -0,0 +1,189 @@
+package projectinfo
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// FileClass is a set of flags for files that are usually less interesting than hand-written source code
+type FileClass int
+
+// File classes, which can be combined, as in ClassGenerated | ClassVendored
+const (
+	ClassGenerated     FileClass = 1 << iota // generated by a tool, like protobuf stubs, *_gen.go files and lockfiles
+	ClassVendored                            // third-party code that has been copied into the project
+	ClassMinified                            // minified JavaScript, CSS or data
+	ClassTest                                // tests and test data
+	ClassDocumentation                       // documentation, or files in a documentation directory
+)
+
+var (
+	// generatedHeader matches the comments that code generators put at the top of files, like the "Code generated ... DO NOT EDIT." line for Go
+	generatedHeader = regexp.MustCompile(`(?im)^\W*(code generated\b.*\bdo not edit\b|.*@generated\b|.*\b(auto-?generated|automatically generated|generated (by|from|with))\b.*\bdo not (edit|modify)\b)`)
+
+	// generatedNames matches the names of files that are generated by common tools, including lockfiles
+	generatedNames = regexp.MustCompile(`(?i)(\.pb\.(go|cc|h|c|js|ts)|\.pb\.gw\.go|_pb2(_grpc)?\.pyi?|_grpc\.pb\.go|_gen\.go|_generated\.\w+|\.generated\.\w+|\.g\.dart|\.freezed\.dart|\.designer\.cs|^zz_generated\..*|^(package-lock\.json|npm-shrinkwrap\.json|yarn\.lock|pnpm-lock\.yaml|cargo\.lock|gemfile\.lock|composer\.lock|poetry\.lock|pipfile\.lock|go\.sum|flake\.lock|mix\.lock|pubspec\.lock|podfile\.lock))$`)
+
+	// vendoredDirs are directories that usually hold third-party code
+	vendoredDirs = map[string]bool{"vendor": true, "vendors": true, "third_party": true, "thirdparty": true, "third-party": true, "external": true, "extern": true, "node_modules": true, "bower_components": true, "pods": true, "carthage": true, "deps": true, ".yarn": true}
+
+	// testNames matches the names of files with tests, for the conventions of common languages
+	testNames = regexp.MustCompile(`(_test\.go|^test_.*\.py|_test\.py|\.(test|spec)\.[cm]?[jt]sx?|_spec\.rb|_test\.rb|Tests?\.(java|kt|cs|swift|scala)|_test\.(c|cc|cpp|rs|exs|dart)|Spec\.(scala|groovy|kt))$`)
+
+	// testDirs are directories that usually hold tests or test data
+	testDirs = map[string]bool{"test": true, "tests": true, "__tests__": true, "spec": true, "specs": true, "testdata": true, "test_data": true, "fixtures": true, "__snapshots__": true}
+
+	// docDirs are directories that usually hold documentation
+	docDirs = map[string]bool{"doc": true, "docs": true, "documentation": true, "man": true}
+
+	// minifiedNames matches the names of minified files
+	minifiedNames = regexp.MustCompile(`(?i)[.-]min\.(js|css|mjs)$`)
+)
+
+// inDirectory checks if any of the directories in a slash separated relative path is in the given set, ignoring the case
+func inDirectory(relPath string, dirs map[string]bool) bool {
+	parts := strings.Split(relPath, "/")
+	for _, dir := range parts[:len(parts)-1] {
+		if dirs[strings.ToLower(dir)] {
+			return true
+		}
+	}
+	return false
+}
+
+// IsGenerated checks if a file was generated by a tool, by its name or by a header comment like "Code generated ... DO NOT EDIT."
+// Only the first lines of the contents are looked at.
+func IsGenerated(filename, contents string) bool {
+	if generatedNames.MatchString(path.Base(filename)) {
+		return true
+	}
+	head := contents
+	for i, lines := 0, 0; i < len(head); i++ {
+		if head[i] == '\n' {
+			if lines++; lines == 10 {
+				head = head[:i]
+				break
+			}
+		}
+	}
+	return generatedHeader.MatchString(head)
+}
+
+// IsVendored checks if a file, given as a path relative to the project directory, is in a directory for third-party code
+func IsVendored(relPath string) bool {
+	return inDirectory(relPath, vendoredDirs)
+}
+
+// IsTestFile checks if a file, given as a path relative to the project directory, has tests or test data
+func IsTestFile(relPath string) bool {
+	return testNames.MatchString(path.Base(relPath)) || inDirectory(relPath, testDirs)
+}
+
+// IsMinified checks if a file is minified, either by its name, like "app.min.js", or by having long lines with little whitespace
+func IsMinified(filename, contents string) bool {
+	if minifiedNames.MatchString(path.Base(filename)) {
+		return true
+	}
+	if len(contents) < 1024 {
+		return false
+	}
+	var lines, longest, current, spaces int
+	for i := 0; i < len(contents); i++ {
+		switch contents[i] {
+		case '\n':
+			lines++
+			current = 0
+			continue
+		case ' ', '\t':
+			spaces++
+		}
+		if current++; current > longest {
+			longest = current
+		}
+	}
+	if contents[len(contents)-1] != '\n' {
+		lines++
+	}
+	averageLine := len(contents) / lines
+	return averageLine > 250 || (longest > 1000 && float64(spaces)/float64(len(contents)) < 0.05)
+}
+
+// classifyFile returns the classes of a file, from its path relative to the project directory, its category and its contents
+func classifyFile(relPath, category, contents string) FileClass {
+	relPath = strings.TrimPrefix(path.Clean("/"+relPath), "/")
+	var classes FileClass
+	if IsGenerated(relPath, contents) {
+		classes |= ClassGenerated
+	}
+	if IsVendored(relPath) {
+		classes |= ClassVendored
+	}
+	if IsMinified(relPath, contents) {
+		classes |= ClassMinified
+	}
+	if IsTestFile(relPath) {
+		classes |= ClassTest
+	}
+	if category == CategoryDocumentation || inDirectory(relPath, docDirs) {
+		classes |= ClassDocumentation
+	}
+	return classes
+}
+
+// setClasses sets the flags of a FileInfo from the given classes
+func (fileInfo *FileInfo) setClasses(classes FileClass) {
+	fileInfo.Generated = classes&ClassGenerated != 0
+	fileInfo.Vendored = classes&ClassVendored != 0
+	fileInfo.Minified = classes&ClassMinified != 0
+	fileInfo.Test = classes&ClassTest != 0
+	fileInfo.Documentation = classes&ClassDocumentation != 0
+}
+
+// Classes returns the classes of the file, as given by its Generated, Vendored, Minified, Test and Documentation flags
+func (fileInfo FileInfo) Classes() FileClass {
+	var classes FileClass
+	for _, flag := range []struct {
+		set   bool
+		class FileClass
+	}{
+		{fileInfo.Generated, ClassGenerated},
+		{fileInfo.Vendored, ClassVendored},
+		{fileInfo.Minified, ClassMinified},
+		{fileInfo.Test, ClassTest},
+		{fileInfo.Documentation, ClassDocumentation},
+	} {
+		if flag.set {
+			classes |= flag.class
+		}
+	}
+	return classes
+}
+
+// downRank moves the files that are in any of the given classes to the end, keeping the order otherwise
+func downRank(files []FileInfo, classes FileClass) {
+	if classes == 0 {
+		return
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].Classes()&classes == 0 && files[j].Classes()&classes != 0
+	})
+}
+
+// rankedFiles returns the files that are not in any of the given classes, or all files if every file is in one of them
+func rankedFiles(files []FileInfo, classes FileClass) []FileInfo {
+	if classes == 0 {
+		return files
+	}
+	var ranked []FileInfo
+	for _, file := range files {
+		if file.Classes()&classes == 0 {
+			ranked = append(ranked, file)
+		}
+	}
+	if len(ranked) == 0 {
+		return files
+	}
+	return ranked
+}
@@ -0,0 +1,110 @@
+package projectinfo
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestClassifyFile(t *testing.T) {
+	minifiedJS := strings.Repeat("var a=function(b){return b+1};", 100)
+	longLines := strings.Repeat(strings.Repeat("x = 1\n", 20), 20)
+	testCases := []struct {
+		relPath  string
+		category string
+		contents string
+		want     FileClass
+	}{
+		{"main.go", CategorySource, "package main\n", 0},
+		{"api/service.go", CategorySource, "// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage api\n", ClassGenerated},
+		{"api/service.pb.go", CategorySource, "package api\n", ClassGenerated},
+		{"internal/enum_gen.go", CategorySource, "package internal\n", ClassGenerated},
+		{"proto/service_pb2.py", CategorySource, "", ClassGenerated},
+		{"src/Schema.java", CategorySource, "/*\n * @generated\n */\n", ClassGenerated},
+		{"web/package-lock.json", CategoryConfiguration, "{}\n", ClassGenerated},
+		{"README.md", CategoryDocumentation, "This tool has generated many reports.\n", ClassDocumentation},
+		{"third_party/zlib/inflate.c", CategorySource, "", ClassVendored},
+		{"lib/External/x.go", CategorySource, "", ClassVendored},
+		{"static/app.min.js", CategorySource, "", ClassMinified},
+		{"static/bundle.js", CategorySource, minifiedJS, ClassMinified},
+		{"static/app.js", CategorySource, longLines, 0},
+		{"main_test.go", CategorySource, "package main\n", ClassTest},
+		{"tests/test_parser.py", CategorySource, "", ClassTest},
+		{"src/Button.test.tsx", CategorySource, "", ClassTest},
+		{"src/test/java/AppTest.java", CategorySource, "", ClassTest},
+		{"testdata/input.json", CategoryConfiguration, "{}\n", ClassTest},
+		{"contest/main.go", CategorySource, "", 0},
+		{"docs/guide.md", CategoryDocumentation, "", ClassDocumentation},
+		{"docs/examples/main.go", CategorySource, "", ClassDocumentation},
+		{"vendor/github.com/x/y/y_test.go", CategorySource, "", ClassVendored | ClassTest},
+	}
+	for _, tc := range testCases {
+		if got := classifyFile(tc.relPath, tc.category, tc.contents); got != tc.want {
+			t.Errorf("classifyFile(%q) = %b, want %b", tc.relPath, got, tc.want)
+		}
+	}
+}
+
+func TestExcludeAndDownRankClasses(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.go":                "package main\n",
+		"main_test.go":           "package main\n",
+		"util_test.go":           "package main\n",
+		"util.go":                "package main\n",
+		"api/api.pb.go":          "// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage api\n",
+		"third_party/lib/lib.py": "print('vendored')\n",
+		"static/app.min.js":      "var a=1;\n",
+		"docs/guide.md":          "# Guide\n",
+		"README.md":              "# Project\n",
+	}
+	for filename, contents := range files {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(filename)), 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := setupMockFile(dir, filename, contents); err != nil {
+			t.Fatalf("Failed to write %s: %v", filename, err)
+		}
+	}
+	relPaths := func(files []FileInfo) []string {
+		var paths []string
+		for _, file := range files {
+			rel, _ := filepath.Rel(dir, file.Path)
+			paths = append(paths, filepath.ToSlash(rel))
+		}
+		return paths
+	}
+
+	project, err := NewWithOptions(dir, Options{NoGit: true, NoAPIServerCheck: true, Workers: 1})
+	if err != nil {
+		t.Fatalf("NewWithOptions() error = %v", err)
+	}
+	if got := len(project.SourceFiles); got != 7 {
+		t.Errorf("got %d source files without any options, want 7", got)
+	}
+	generated := FindFileName(project.SourceFiles, "api.pb.go")
+	if !generated.Generated || generated.Classes() != ClassGenerated {
+		t.Errorf("api.pb.go has the classes %b, want generated", generated.Classes())
+	}
+
+	project, err = NewWithOptions(dir, Options{
+		NoGit:            true,
+		NoAPIServerCheck: true,
+		ExcludeClasses:   ClassGenerated | ClassVendored | ClassMinified | ClassDocumentation,
+		DownRankClasses:  ClassTest,
+	})
+	if err != nil {
+		t.Fatalf("NewWithOptions() error = %v", err)
+	}
+	if got, want := relPaths(project.SourceFiles), []string{"main.go", "util.go", "main_test.go", "util_test.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("source files = %v, want %v", got, want)
+	}
+	if len(project.ConfAndDocFiles) != 0 {
+		t.Errorf("documentation files = %v, want none", relPaths(project.ConfAndDocFiles))
+	}
+	if project.Type != "Go" {
+		t.Errorf("project type = %q, want Go", project.Type)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 
@@ -24,14 +25,28 @@ func OutputChunks(dir string) error {
 }
 
 func main() {
+	bpeFilename := flag.String("bpe", "", "BPE vocabulary (tiktoken ranks or merges.txt) to count tokens with")
+	maxTokens := flag.Int("max-tokens", projectinfo.DefaultMaxTokensPerChunk, "maximum number of tokens per chunk")
+	flag.Parse()
+
 	// Check for command line arguments
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: info [directory]")
+	if flag.NArg() < 1 {
+		fmt.Println("Usage: info [-bpe vocabulary] [-max-tokens n] [directory]")
 		os.Exit(1)
 	}
 
 	// The first argument should be the directory to scan
-	dir := os.Args[1]
+	dir := flag.Arg(0)
+
+	if *bpeFilename != "" {
+		bpe, err := projectinfo.LoadBPETokenizer(*bpeFilename)
+		if err != nil {
+			fmt.Printf("Failed to load the BPE vocabulary: %v\n", err)
+			os.Exit(1)
+		}
+		projectinfo.SetTokenizer(bpe)
+	}
+	projectinfo.SetMaxTokensPerChunk(*maxTokens)
 
 	if err := OutputChunks(dir); err != nil {
 		fmt.Printf("Failed to output project chunks: %v\n", err)
@@ -3,7 +3,6 @@ package projectinfo
 import (
 	"bufio"
 	"strings"
-	"unicode/utf8"
 )
 
 // CountLines counts the number of lines in a string, often used to determine the size of file contents
@@ -16,8 +15,30 @@ func CountLines(content string) (int, error) {
 	return lineCount, scanner.Err()
 }
 
-// CountTokens estimates the number of tokens in a string based on UTF-8 rune count
+// CountTokens counts the number of tokens in a string, using the tokenizer that has been set with SetTokenizer
 func CountTokens(input string) int {
-	runeCount := utf8.RuneCountInString(input)
-	return (runeCount + 3) / 4 // A rough estimate of token count, suitable for simple use cases
+	return tokenizer.CountTokens(input)
+}
+
+// TokenStats holds aggregated token counts for the files of a project
+type TokenStats struct {
+	Total           int            `json:"total"`
+	SourceFiles     int            `json:"sourceFiles"`
+	ConfAndDocFiles int            `json:"confAndDocFiles"`
+	PerLanguage     map[string]int `json:"perLanguage"`
+}
+
+// SumTokens adds up the token counts of the given source files and configuration and documentation files
+func SumTokens(sourceFiles, confAndDocFiles []FileInfo) TokenStats {
+	stats := TokenStats{PerLanguage: make(map[string]int)}
+	for _, file := range sourceFiles {
+		stats.SourceFiles += file.TokenCount
+		stats.PerLanguage[file.Language] += file.TokenCount
+	}
+	for _, file := range confAndDocFiles {
+		stats.ConfAndDocFiles += file.TokenCount
+		stats.PerLanguage[file.Language] += file.TokenCount
+	}
+	stats.Total = stats.SourceFiles + stats.ConfAndDocFiles
+	return stats
 }
@@ -0,0 +1,202 @@
+package projectinfo
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// detectHeadSize is how many bytes from the start of a file are used for detecting its language
+const detectHeadSize = 8 * 1024
+
+// ambiguousExtensions lists the languages that files with these extensions may be written in.
+// If the contents do not clearly point to one of them, the language the registry has for the extension is used, like "C/C++ Header" for ".h".
+var ambiguousExtensions = map[string][]string{
+	".h":  {"C", "C++", "Objective-C"},
+	".m":  {"Objective-C", "MATLAB"},
+	".pl": {"Perl", "Prolog"},
+}
+
+// keywordProfile is a set of patterns that are typical for a language, and rarely seen in the languages it may be confused with
+type keywordProfile struct {
+	language string
+	patterns []*regexp.Regexp
+}
+
+// keywordProfiles are used for classifying files by keyword statistics, when there is no shebang or modeline
+var keywordProfiles = []keywordProfile{
+	{"C", compilePatterns(`^\s*#\s*include\s*<(stdio|stdlib|string|stdint|stddef|stdbool|unistd|errno|assert|signal|fcntl)\.h>`, `\b(malloc|calloc|realloc|free)\(`, `^\s*typedef\s+(struct|union|enum)\b`, `^\s*#\s*ifdef\s+__cplusplus\b`, `^\s*extern\s+"C"`, `\(void\)\s*[;{]?\s*$`)},
+	{"C++", compilePatterns(`^\s*(class|namespace|template\s*<|using\s+namespace)\b`, `\bstd::`, `^\s*(public|private|protected):`, `\b(nullptr|virtual|constexpr|noexcept)\b`, `^\s*#\s*include\s*<(iostream|string|vector|memory|map|algorithm|cstdint)>`)},
+	{"Objective-C", compilePatterns(`^\s*@(interface|implementation|protocol|end|property|synthesize|class)\b`, `^\s*#\s*import\b`, `\[\[?\w+\s+alloc\]`, `\bNS(String|Object|Array|Dictionary|Integer)\b`, `^\s*[-+]\s*\(\w+\s*\*?\)`)},
+	{"MATLAB", compilePatterns(`^\s*%`, `^\s*function\s+(\[[^\]]*\]|\w+)\s*=`, `^\s*end\s*$`, `\b(disp|zeros|ones|numel|fprintf)\(`, `\.\*|\.\^|\.'`)},
+	{"Perl", compilePatterns(`\bmy\s+[$@%]`, `^\s*use\s+(strict|warnings)\b`, `^\s*sub\s+\w+\s*\{`, `\$_\b`, `=~\s*[msy]?/`, `^\s*package\s+[\w:]+;`)},
+	{"Prolog", compilePatterns(`:-`, `^\s*[a-z]\w*\([^)]*\)\s*\.\s*$`, `\b(format|write|nl|assert[az]?)\(`, `^\s*%`)},
+	{"Python", compilePatterns(`^\s*def\s+\w+\(.*\):\s*$`, `^\s*(from\s+[\w.]+\s+)?import\s+[\w.]+(\s+as\s+\w+)?\s*$`, `^\s*if\s+__name__\s*==`, `\bself\.`, `^\s*(elif\b|except\b|class\s+\w+.*:\s*$)`)},
+	{"Ruby", compilePatterns(`^\s*require(_relative)?\s+['"]`, `^\s*def\s+\w+[?!]?(\(.*\))?\s*$`, `\bputs\b`, `\bdo\s*\|`, `^\s*module\s+[A-Z]`, `\battr_(reader|writer|accessor)\b`)},
+	{"Shell", compilePatterns(`;\s*(then|do)\s*$`, `^\s*(fi|done|esac)\s*$`, `^\s*export\s+\w+=`, `\$\{\w+`, `^\s*echo\b`, `^\s*\w+\(\)\s*\{`)},
+	{"JavaScript", compilePatterns(`\b(const|let)\s+\w+\s*=`, `\bconsole\.log\(`, `\brequire\(['"]`, `\bmodule\.exports\b`, `=>\s*\{`)},
+	{"PHP", compilePatterns(`<\?php`, `\$this->`, `^\s*namespace\s+[\w\\]+;`, `\becho\s+\$`)},
+	{"Lua", compilePatterns(`\blocal\s+\w+\s*=`, `\bfunction\s+[\w.:]+\(`, `\bthen\s*$`, `\bnil\b`, `--\[\[`)},
+}
+
+// compilePatterns compiles regular expressions that are matched against each line of a file
+func compilePatterns(patterns ...string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		compiled[i] = regexp.MustCompile("(?m)" + pattern)
+	}
+	return compiled
+}
+
+var (
+	vimModeline   = regexp.MustCompile(`(?:^|\s)(?:vi|vim|ex)(?:[<=>]?\d+)?:.*?\b(?:ft|filetype|syntax)=([\w+#-]+)`)
+	emacsModeline = regexp.MustCompile(`-\*-\s*(?:.*?\bmode:\s*([\w+#-]+)|([\w+#-]+))\s*(?:;.*?)?-\*-`)
+)
+
+// needsDetection checks if the language of a file can only be known by looking at its contents,
+// because it has no extension and no known file name, or an ambiguous extension
+func (registry *LanguageRegistry) needsDetection(path string) bool {
+	registry.mu.RLock()
+	_, known := registry.byFilename[strings.ToLower(filepath.Base(path))]
+	registry.mu.RUnlock()
+	if known {
+		return false
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	_, ambiguous := ambiguousExtensions[ext]
+	return ext == "" || ambiguous
+}
+
+// Detect determines the language of a file from its name and, if the extension is missing or ambiguous,
+// from the shebang line, vim or emacs modelines and keyword statistics of its contents
+func (registry *LanguageRegistry) Detect(path string, contents []byte) (Language, bool) {
+	fallback, found := registry.ForPath(path)
+	if !registry.needsDetection(path) {
+		return fallback, found
+	}
+	if bytes.IndexByte(contents, 0) >= 0 { // binary
+		return fallback, found
+	}
+	text := string(contents)
+	if language, ok := registry.shebangLanguage(text); ok {
+		return language, true
+	}
+	if language, ok := registry.modelineLanguage(text); ok {
+		return language, true
+	}
+	if candidates, ok := ambiguousExtensions[strings.ToLower(filepath.Ext(path))]; ok {
+		if language, ok := registry.keywordLanguage(text, candidates, 2); ok {
+			return language, true
+		}
+		return fallback, found
+	}
+	// Extensionless files are only classified by keywords if there is strong evidence, since they are often plain text
+	return registry.keywordLanguage(text, nil, 4)
+}
+
+// shebangLanguage finds the language of the interpreter in a "#!" line, like "#!/usr/bin/env python3"
+func (registry *LanguageRegistry) shebangLanguage(text string) (Language, bool) {
+	if !strings.HasPrefix(text, "#!") {
+		return Language{}, false
+	}
+	line, _, _ := strings.Cut(text[2:], "\n")
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return Language{}, false
+	}
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		interpreter = ""
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") { // skip options like -S and variables like LANG=C
+				interpreter = filepath.Base(field)
+				break
+			}
+		}
+	}
+	if interpreter == "" {
+		return Language{}, false
+	}
+	if language, ok := registry.ForInterpreter(interpreter); ok {
+		return language, true
+	}
+	return registry.ForInterpreter(strings.TrimRight(interpreter, "0123456789.")) // like python3.11 or ruby2.7
+}
+
+// modelineLanguage finds the language in a vim modeline in the first or last five lines,
+// or in an emacs modeline in the first two lines
+func (registry *LanguageRegistry) modelineLanguage(text string) (Language, bool) {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if i >= 5 && i < len(lines)-5 {
+			continue
+		}
+		if i < 2 {
+			if m := emacsModeline.FindStringSubmatch(line); m != nil {
+				mode := strings.TrimSuffix(m[1]+m[2], "-mode")
+				if language, ok := registry.Lookup(mode); ok {
+					return language, true
+				}
+			}
+		}
+		if m := vimModeline.FindStringSubmatch(line); m != nil {
+			if language, ok := registry.Lookup(m[1]); ok {
+				return language, true
+			}
+		}
+	}
+	return Language{}, false
+}
+
+// keywordLanguage scores the given candidate languages, or all languages with a keyword profile if candidates is nil,
+// by how many of their typical patterns match. The best language needs at least minScore more matches than the runner-up, and twice as many.
+func (registry *LanguageRegistry) keywordLanguage(text string, candidates []string, minScore int) (Language, bool) {
+	best, bestScore, secondScore := "", 0, 0
+	for _, profile := range keywordProfiles {
+		if candidates != nil && !slices.Contains(candidates, profile.language) {
+			continue
+		}
+		score := 0
+		for _, re := range profile.patterns {
+			score += len(re.FindAllStringIndex(text, -1))
+		}
+		switch {
+		case score > bestScore:
+			best, bestScore, secondScore = profile.language, score, bestScore
+		case score > secondScore:
+			secondScore = score
+		}
+	}
+	if bestScore-secondScore < minScore || bestScore < 2*secondScore {
+		return Language{}, false
+	}
+	return registry.Lookup(best)
+}
+
+// readHead reads up to n bytes from the start of a file
+func readHead(path string, n int) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	head := make([]byte, n)
+	read, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return head[:read], nil
+}
+
+// DetectLanguage determines the language of a file from its name and, if the extension is missing or ambiguous, from its contents.
+// It returns "Unknown" if the language could not be determined.
+func DetectLanguage(path string, contents []byte) string {
+	if language, ok := DefaultLanguages.Detect(path, contents); ok {
+		return language.Name
+	}
+	return "Unknown"
+}
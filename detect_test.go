@@ -0,0 +1,80 @@
+package projectinfo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	testCases := []struct {
+		path     string
+		contents string
+		want     string
+	}{
+		{"bin/tool", "#!/usr/bin/env python\nprint('hi')\n", "Python"},
+		{"bin/tool", "#!/usr/bin/env -S LANG=C python3.11 -u\n", "Python"},
+		{"bin/tool", "#!/bin/bash\necho hi\n", "Shell"},
+		{"bin/tool", "#!/usr/bin/perl -w\n", "Perl"},
+		{"bin/tool", "puts 'hi'\n# vim: set ft=ruby ts=2:\n", "Ruby"},
+		{"bin/tool", "# -*- mode: sh; coding: utf-8 -*-\nls\n", "Shell"},
+		{"bin/tool", ";; -*- lua -*-\n", "Lua"},
+		{"bin/tool", "import os\nimport sys\n\ndef main():\n    print(sys.argv)\n\nif __name__ == '__main__':\n    main()\n", "Python"},
+		{"VERSION", "1.2.3\n", "Unknown"},
+		{"bin/tool", "\x7fELF\x00\x00", "Unknown"},
+		{"include/util.h", "#pragma once\nint add(int a, int b);\n", "C/C++ Header"},
+		{"include/util.h", "#pragma once\nclass Parser;\nint parse(void);\n", "C/C++ Header"},
+		{"include/list.h", "#include <stdlib.h>\ntypedef struct list *List;\nList list_new(void);\n#ifdef __cplusplus\nextern \"C\" {\n#endif\n", "C"},
+		{"include/util.h", "#pragma once\n#include <string>\nnamespace util {\nclass Parser {\npublic:\n    std::string parse();\n};\n}\n", "C++"},
+		{"include/util.h", "#import <Foundation/Foundation.h>\n@interface Parser : NSObject\n@property NSString *name;\n@end\n", "Objective-C"},
+		{"include/util.h", "// -*- mode: c++ -*-\nint add(int a, int b);\n", "C++"},
+		{"src/main.m", "#import \"AppDelegate.h\"\n@implementation AppDelegate\n- (void)run {\n}\n@end\n", "Objective-C"},
+		{"src/stats.m", "% compute the mean\nfunction m = stats(x)\n    m = sum(x) / numel(x);\n    disp(m)\nend\n", "MATLAB"},
+		{"src/family.pl", "% facts\nparent(tom, bob).\nparent(bob, ann).\ngrandparent(X, Z) :- parent(X, Y), parent(Y, Z).\n", "Prolog"},
+		{"src/tool.pl", "use strict;\nuse warnings;\nmy $name = shift;\nprint \"$name\\n\";\n", "Perl"},
+		{"src/tool.pl", "", "Perl"},
+		{"main.go", "#!/usr/bin/env python\n", "Go"},
+		{"Makefile", "all:\n\tgo build\n", "Makefile"},
+	}
+	for _, tc := range testCases {
+		if got := DetectLanguage(tc.path, []byte(tc.contents)); got != tc.want {
+			t.Errorf("DetectLanguage(%q, %q) = %q, want %q", tc.path, tc.contents, got, tc.want)
+		}
+	}
+}
+
+func TestCollectDetectedFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"deploy":      "#!/bin/sh\nset -e\nmake install\n",
+		"VERSION":     "1.2.3\n",
+		"lib/table.h": "#pragma once\ntemplate <typename T>\nclass Table {\n};\n",
+		"main.go":     "package main\n",
+	}
+	for filename, contents := range files {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(filename)), 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := setupMockFile(dir, filename, contents); err != nil {
+			t.Fatalf("Failed to write %s: %v", filename, err)
+		}
+	}
+	sourceFiles, err := CollectFiles(dir, nil, false, false, false)
+	if err != nil {
+		t.Fatalf("CollectFiles() error = %v", err)
+	}
+	languages := make(map[string]string)
+	for _, file := range sourceFiles {
+		rel, _ := filepath.Rel(dir, file.Path)
+		languages[filepath.ToSlash(rel)] = file.Language
+	}
+	want := map[string]string{"deploy": "Shell", "lib/table.h": "C++", "main.go": "Go"}
+	if len(languages) != len(want) {
+		t.Errorf("collected %v, want %v", languages, want)
+	}
+	for path, language := range want {
+		if languages[path] != language {
+			t.Errorf("language of %s = %q, want %q", path, languages[path], language)
+		}
+	}
+}
@@ -2,80 +2,278 @@ package projectinfo
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 	"unicode/utf8"
 )
 
-// FileInfo represents information about a file in the project, including its content-related attributes
+// FileInfo represents information about a file in the project, including its content-related attributes.
+// If the file has been split into several parts by SplitFile, Part, TotalParts, StartLine and EndLine describe which part of the file Contents holds.
 type FileInfo struct {
-	Path         string   `json:"path"`
-	Language     string   `json:"language"`
-	LastModified string   `json:"last_modified,omitempty"`
-	Contents     string   `json:"contents,omitempty"`
-	LineCount    int      `json:"line_count,omitempty"`
-	TokenCount   int      `json:"token_count"`
-	Contributors []string `json:"contributors"`
+	Path          string          `json:"path"`
+	Language      string          `json:"language"`
+	Category      string          `json:"category,omitempty"`
+	LastModified  string          `json:"last_modified,omitempty"` // RFC 3339, from ModTime or, with Options.LastModifiedFromGit, from the last commit
+	ModTime       string          `json:"mod_time,omitempty"`      // the modification time of the file in the file system, RFC 3339
+	LastCommit    string          `json:"last_commit,omitempty"`   // the hash of the last commit that changed the file
+	LastAuthor    string          `json:"last_author,omitempty"`   // the author of the last commit that changed the file
+	Contents      string          `json:"contents,omitempty"`
+	LineCount     int             `json:"line_count,omitempty"`
+	TokenCount    int             `json:"token_count"`
+	Contributors  []string        `json:"contributors"`
+	Ownership     []LineOwnership `json:"ownership,omitempty"`
+	Churn         *FileChurn      `json:"churn,omitempty"`
+	Part          int             `json:"part,omitempty"`
+	TotalParts    int             `json:"total_parts,omitempty"`
+	StartLine     int             `json:"start_line,omitempty"`
+	EndLine       int             `json:"end_line,omitempty"`
+	Permalink     string          `json:"permalink,omitempty"`     // a web link to the file at the HEAD commit, and to the lines of this part if the file is unchanged since then
+	Generated     bool            `json:"generated,omitempty"`     // generated by a tool, see IsGenerated
+	Vendored      bool            `json:"vendored,omitempty"`      // third-party code, see IsVendored
+	Minified      bool            `json:"minified,omitempty"`      // see IsMinified
+	Test          bool            `json:"test,omitempty"`          // tests or test data, see IsTestFile
+	Documentation bool            `json:"documentation,omitempty"` // documentation, or a file in a documentation directory
+
+	link      *fileLink // for making permalinks to the parts of the file
+	unchanged bool      // the file is the same as in the HEAD commit, so that line numbers in permalinks are right
+}
+
+// CollectFiles walks through a directory recursively and collects files that have the right extensions.
+// Along the way, the .gitignore and .ignore files of the visited directories, .git/info/exclude and the global git excludes file are added to ignores.
+func CollectFiles(dir string, ignores *Ignorer, alsoDocOrConf, alsoContributors, verbose bool) ([]FileInfo, error) {
+	opts := Options{NoGit: !alsoContributors, Logger: log.Default(), Verbose: verbose}
+	histories := newGitHistories()
+	defer histories.Close()
+	sourceFiles, confAndDocFiles, _, err := collectFiles(context.Background(), dir, ignores, histories, &opts)
+	if alsoDocOrConf {
+		return confAndDocFiles, err
+	}
+	return sourceFiles, err
+}
+
+// fileJob is a file that has been found by the directory walk, and is waiting to be read and analyzed
+type fileJob struct {
+	path     string
+	relPath  string // relative to the project directory
+	language string
+	category string
+	detect   bool // the language has to be detected from the contents, since the extension is missing or ambiguous
+}
+
+// collectFiles walks through a directory recursively, in a single pass, and collects both the source files
+// and the documentation, configuration and build files, as configured by the options.
+// The files are read and analyzed concurrently, but are returned in the order they were found.
+// The contributors, line ownership and churn of each file are looked up in the given git histories, unless opts.NoGit is set.
+// The directories of git submodules and nested repositories are returned as subDirs, and their files are only collected
+// if opts.SubProjects is SubProjectsInline.
+func collectFiles(ctx context.Context, dir string, ignores *Ignorer, histories *gitHistories, opts *Options) (sourceFiles, confAndDocFiles []FileInfo, subDirs []string, err error) {
+	jobs, subDirs, err := findFiles(ctx, dir, ignores, opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	results := make([]*FileInfo, len(jobs))
+	jobIndices := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < opts.workers(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobIndices {
+				results[i] = analyzeFile(jobs[i], histories, opts)
+			}
+		}()
+	}
+sendJobs:
+	for i := range jobs {
+		select {
+		case jobIndices <- i:
+		case <-ctx.Done():
+			break sendJobs
+		}
+	}
+	close(jobIndices)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+	for _, fileInfo := range results {
+		if fileInfo == nil {
+			continue // the file was skipped
+		}
+		if fileInfo.Category == CategorySource {
+			sourceFiles = append(sourceFiles, *fileInfo)
+		} else {
+			confAndDocFiles = append(confAndDocFiles, *fileInfo)
+		}
+	}
+	downRank(sourceFiles, opts.DownRankClasses)
+	downRank(confAndDocFiles, opts.DownRankClasses)
+	return sourceFiles, confAndDocFiles, subDirs, nil
 }
 
-// CollectFiles walks through a directory recursively and collects files that have the right extensions
-func CollectFiles(dir string, ignores map[string]struct{}, alsoDocOrConf, alsoContributors, verbose bool) ([]FileInfo, error) {
-	var files []FileInfo
-	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
-		if verbose {
-			fmt.Printf("Visiting: %s\n", path)
+// findFiles walks through a directory recursively and returns the files that should be collected, in walk order,
+// and the directories of git submodules and nested repositories
+func findFiles(ctx context.Context, dir string, ignores *Ignorer, opts *Options) ([]fileJob, []string, error) {
+	if ignores == nil {
+		ignores = NewIgnorer(dir)
+	}
+	ignores.AddGitExcludes(dir)
+	includes, err := newIncludeMatcher(opts.Include)
+	if err != nil {
+		return nil, nil, err
+	}
+	submodules := readGitModules(dir)
+	var (
+		jobs    []fileJob
+		subDirs []string
+	)
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if opts.Verbose {
+			opts.logf("Visiting: %s\n", path)
 		}
 		if err != nil {
-			log.Printf("Error accessing path %s: %v\n", path, err)
+			opts.logf("Error accessing path %s: %v\n", path, err)
 			return nil // Continue to the next file
 		}
-		if ShouldSkip(path, ignores) {
+		if path != dir && vcsDirs[d.Name()] {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil // a .git file that points to the git directory of a worktree or submodule
+		}
+		// Parent directories that are ignored have already been skipped, so only the path itself needs to be checked
+		if path != dir && ignores.Match(path, d.IsDir()) {
 			if d.IsDir() {
 				return fs.SkipDir
 			}
 			return nil // Skip file
 		}
-		if !d.IsDir() && (RecognizedExtension(path, alsoDocOrConf) || RecognizedFilename(path, alsoDocOrConf)) {
-			ext := filepath.Ext(path)
-			language := LanguageFromExtension(ext)
-			if language != "Unknown" {
-				fi, err := os.Stat(path)
-				if err != nil {
-					log.Printf("Error getting file info for %s: %v\n", path, err)
-					return nil // Continue to the next file
-				}
-				content, err := os.ReadFile(path)
-				if err != nil {
-					log.Printf("Error reading file %s: %v\n", path, err)
-					return nil // Continue to the next file
-				}
-				utf8Content, err := ConvertToUTF8(content)
-				if err != nil {
-					log.Printf("Error converting file %s to UTF-8: %v\n", path, err)
-					return nil // Continue to the next file
-				}
-				stringContent := string(utf8Content)
-				lineCount, _ := CountLines(stringContent)
-				fileInfo := FileInfo{
-					Path:         path,
-					Language:     language,
-					LineCount:    lineCount,
-					LastModified: fi.ModTime().Format("2006-01-02 15:04:05"),
-					Contents:     stringContent,
-				}
-				if alsoContributors {
-					fileInfo.Contributors = maybeGitContributorsForFile(path)
+		if d.IsDir() {
+			if path != dir {
+				if rel, err := filepath.Rel(dir, path); err == nil {
+					if _, ok := submodules[filepath.ToSlash(rel)]; ok || isNestedRepository(path) {
+						subDirs = append(subDirs, path)
+						if opts.SubProjects != SubProjectsInline {
+							return fs.SkipDir
+						}
+					}
 				}
-				files = append(files, fileInfo)
 			}
+			if err := ignores.AddDir(path); err != nil {
+				opts.logf("Error reading ignore files in %s: %v\n", path, err)
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			rel = path
+		}
+		rel = filepath.ToSlash(rel)
+		if !includes.Included(rel) {
+			return nil
 		}
+		job := fileJob{path: path, relPath: rel}
+		if languages := opts.languages(); languages.needsDetection(path) {
+			job.detect = true
+		} else if language, ok := languages.ForPath(path); ok {
+			job.language, job.category = language.Name, language.Category
+		} else {
+			return nil
+		}
+		// Skip the excluded classes that can be recognized by the path alone, before reading the file
+		if opts.ExcludeClasses != 0 && classifyFile(rel, job.category, "")&opts.ExcludeClasses != 0 {
+			return nil
+		}
+		jobs = append(jobs, job)
 		return nil
 	})
-	return files, err
+	return jobs, subDirs, err
+}
+
+// analyzeFile reads a file and gathers information about it, or returns nil if the file should be skipped
+func analyzeFile(job fileJob, histories *gitHistories, opts *Options) *FileInfo {
+	path := job.path
+	fi, err := os.Stat(path)
+	if err != nil {
+		opts.logf("Error getting file info for %s: %v\n", path, err)
+		return nil // Continue to the next file
+	}
+	if opts.MaxFileSize > 0 && fi.Size() > opts.MaxFileSize {
+		if opts.Verbose {
+			opts.logf("Skipping %s, since it is larger than %d bytes\n", path, opts.MaxFileSize)
+		}
+		return nil
+	}
+	if job.detect {
+		head, err := readHead(path, detectHeadSize)
+		if err != nil {
+			opts.logf("Error reading file %s: %v\n", path, err)
+			return nil // Continue to the next file
+		}
+		language, ok := opts.languages().Detect(path, head)
+		if !ok {
+			return nil // Not a recognized language
+		}
+		job.language, job.category = language.Name, language.Category
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		opts.logf("Error reading file %s: %v\n", path, err)
+		return nil // Continue to the next file
+	}
+	utf8Content, err := ConvertToUTF8(content)
+	if err != nil {
+		opts.logf("Error converting file %s to UTF-8: %v\n", path, err)
+		return nil // Continue to the next file
+	}
+	stringContent := string(utf8Content)
+	classes := classifyFile(job.relPath, job.category, stringContent)
+	if classes&opts.ExcludeClasses != 0 {
+		if opts.Verbose {
+			opts.logf("Skipping %s, since it is generated, vendored, minified, a test or documentation\n", path)
+		}
+		return nil
+	}
+	lineCount, _ := CountLines(stringContent)
+	fileInfo := FileInfo{
+		Path:         path,
+		Language:     job.language,
+		Category:     job.category,
+		LineCount:    lineCount,
+		TokenCount:   opts.tokenizer().CountTokens(stringContent),
+		LastModified: fi.ModTime().Format(time.RFC3339),
+		ModTime:      fi.ModTime().Format(time.RFC3339),
+	}
+	fileInfo.setClasses(classes)
+	if !opts.NoContents {
+		fileInfo.Contents = stringContent
+	}
+	if !opts.NoGit {
+		fileInfo.Contributors = contributorsForFile(histories, path)
+		if opts.Blame {
+			fileInfo.Ownership = ownershipForFile(histories, path)
+		}
+		fileInfo.Churn = churnForFile(histories, path, opts.ChurnSince, opts.LineStats)
+		fileInfo.unchanged = unchangedSinceHead(histories, path, content)
+		if commit, author := lastCommitForFile(histories, path); commit != nil {
+			fileInfo.LastCommit = commit.hash.String()
+			fileInfo.LastAuthor = author
+			if opts.LastModifiedFromGit {
+				fileInfo.LastModified = commit.committer.When.Format(time.RFC3339)
+			}
+		}
+	}
+	return &fileInfo
 }
 
 // ConvertToUTF8 attempts to convert a byte slice to UTF-8 encoding, managing non-UTF8 encoded parts.
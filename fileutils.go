@@ -1,11 +1,10 @@
 package projectinfo
 
 import (
-	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
-	"strings"
 )
 
 // StripCodeBlockMarkers removes leading and trailing Markdown code block markers (```)
@@ -17,24 +16,23 @@ func StripCodeBlockMarkers(input string) string {
 	return input
 }
 
-// URLFromGitConfig reads the .git/config file and extracts the repository URL from the "remote origin" section
+// URLFromGitConfig reads a git configuration file, like .git/config, and returns the URL of the "origin" remote, or of the first remote if there is no "origin".
+// If .git is a file that points to the git directory, as for worktrees and submodules, the configuration of that git directory is read instead.
 func URLFromGitConfig(configFilePath string) (string, error) {
-	file, err := os.Open(configFilePath)
+	gitDir := filepath.Dir(configFilePath)
+	if fi, err := os.Stat(gitDir); err == nil && !fi.IsDir() {
+		if gitDir, err = readGitDirFile(gitDir); err != nil {
+			return "", err
+		}
+		configFilePath = filepath.Join(readCommonDir(gitDir), "config")
+	}
+	config, err := ParseGitConfig(configFilePath, gitDir)
 	if err != nil {
 		return "", err
 	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	inRemoteSection := false
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.Contains(line, "[remote \"origin\"]") {
-			inRemoteSection = true
-		} else if inRemoteSection && strings.Contains(line, "url =") {
-			return strings.TrimSpace(strings.Split(line, "=")[1]), nil
-		} else if inRemoteSection && line == "" {
-			break // Exit the loop on empty line, which typically ends the section
+	for _, remote := range config.Remotes() {
+		if len(remote.URLs) > 0 {
+			return remote.URLs[0], nil
 		}
 	}
 	return "", fmt.Errorf("no URL found in %s", configFilePath)
@@ -0,0 +1,245 @@
+package projectinfo
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LineOwnership is how many of the current lines of a file were last changed by an author, like "git blame" reports it
+type LineOwnership struct {
+	Author         string  `json:"author"`
+	Email          string  `json:"email,omitempty"`
+	Lines          int     `json:"lines"`
+	Percentage     float64 `json:"percentage"`
+	LastCommitDate string  `json:"last_commit_date,omitempty"` // the most recent commit by this author that changed the file
+}
+
+// blameSuspect is a commit that may have introduced some of the lines of the final version of a file
+type blameSuspect struct {
+	commit *gitCommit
+	blob   gitHash
+	lines  map[int][]int // line number in the version of the file in this commit, to line numbers in the final version
+}
+
+// blobAt returns the blob for the given slash separated path in a tree, if there is one
+func (repo *gitRepository) blobAt(tree gitHash, relPath string) (gitHash, bool) {
+	parts := strings.Split(relPath, "/")
+	for i, part := range parts {
+		entries, err := repo.readTree(tree)
+		if err != nil {
+			return gitHash{}, false
+		}
+		found := false
+		for _, entry := range entries {
+			if entry.name != part {
+				continue
+			}
+			if last := i == len(parts)-1; last == entry.isTree() {
+				return gitHash{}, false
+			}
+			tree, found = entry.hash, true
+			break
+		}
+		if !found {
+			return gitHash{}, false
+		}
+	}
+	return tree, true
+}
+
+// blobLines reads a blob and splits it into lines
+func (repo *gitRepository) blobLines(h gitHash) ([]string, error) {
+	kind, data, err := repo.readObject(h)
+	if err != nil {
+		return nil, err
+	}
+	if kind != gitObjectBlob {
+		return nil, fmt.Errorf("object %s is not a blob", h)
+	}
+	return splitLines(string(data)), nil
+}
+
+// blame finds the commit that last changed each line of the given file, as it is in the HEAD commit.
+// Starting from HEAD, the unchanged lines are passed on to the parent commits, and the lines that
+// differ from all parents are attributed to the commit. Renames are not followed.
+// The returned slice has one commit per line of the file.
+func (history *gitHistory) blame(relPath string) ([]*gitCommit, error) {
+	head, ok := history.all[history.head]
+	if !ok {
+		return nil, nil
+	}
+	blob, ok := history.repo.blobAt(head.tree, relPath)
+	if !ok {
+		return nil, nil
+	}
+	finalLines, err := history.repo.blobLines(blob)
+	if err != nil {
+		return nil, err
+	}
+	owners := make([]*gitCommit, len(finalLines))
+	start := &blameSuspect{commit: head, blob: blob, lines: make(map[int][]int, len(finalLines))}
+	for i := range finalLines {
+		start.lines[i] = []int{i}
+	}
+	pending := map[gitHash]*blameSuspect{head.hash: start}
+	blobs := map[gitHash][]string{blob: finalLines}
+	readLines := func(h gitHash) ([]string, error) {
+		if lines, ok := blobs[h]; ok {
+			return lines, nil
+		}
+		lines, err := history.repo.blobLines(h)
+		if err == nil {
+			blobs[h] = lines
+		}
+		return lines, err
+	}
+
+	for len(pending) > 0 {
+		// Handle the newest commit first, so that all its children are done before it
+		var suspect *blameSuspect
+		for _, candidate := range pending {
+			if suspect == nil || candidate.commit.committer.When.After(suspect.commit.committer.When) ||
+				(candidate.commit.committer.When.Equal(suspect.commit.committer.When) && bytes.Compare(candidate.commit.hash[:], suspect.commit.hash[:]) < 0) {
+				suspect = candidate
+			}
+		}
+		delete(pending, suspect.commit.hash)
+
+		lines, err := readLines(suspect.blob)
+		if err != nil {
+			return nil, err
+		}
+		for _, parentHash := range suspect.commit.parents {
+			if len(suspect.lines) == 0 {
+				break
+			}
+			parent, ok := history.all[parentHash]
+			if !ok {
+				continue
+			}
+			parentBlob, ok := history.repo.blobAt(parent.tree, relPath)
+			if !ok {
+				continue
+			}
+			target, ok := pending[parentHash]
+			if !ok {
+				target = &blameSuspect{commit: parent, blob: parentBlob, lines: make(map[int][]int)}
+			}
+			if parentBlob == suspect.blob {
+				for line, finals := range suspect.lines {
+					target.lines[line] = append(target.lines[line], finals...)
+				}
+				suspect.lines = nil
+			} else {
+				parentLines, err := readLines(parentBlob)
+				if err != nil {
+					return nil, err
+				}
+				for _, match := range matchLines(parentLines, lines) {
+					if finals, ok := suspect.lines[match[1]]; ok {
+						target.lines[match[0]] = append(target.lines[match[0]], finals...)
+						delete(suspect.lines, match[1])
+					}
+				}
+			}
+			if len(target.lines) > 0 {
+				pending[parentHash] = target
+			}
+		}
+		for _, finals := range suspect.lines {
+			for _, final := range finals {
+				owners[final] = suspect.commit
+			}
+		}
+	}
+	return owners, nil
+}
+
+// ownership returns how many of the current lines of the given file each author owns, sorted by the number of lines
+func (history *gitHistory) ownership(relPath string) ([]LineOwnership, error) {
+	owners, err := history.blame(relPath)
+	if err != nil || len(owners) == 0 {
+		return nil, err
+	}
+	byAuthor := make(map[string]*LineOwnership)
+	var authors []*LineOwnership
+	for _, commit := range owners {
+		identity := history.identity(commit.author)
+		owner, ok := byAuthor[identity.Name]
+		if !ok {
+			owner = &LineOwnership{Author: identity.Name, Email: identity.Email}
+			byAuthor[identity.Name] = owner
+			authors = append(authors, owner)
+		}
+		owner.Lines++
+	}
+	latest := make(map[string]time.Time)
+	for _, i := range history.byPath[path.Clean(relPath)] {
+		author := history.commits[i].author
+		name := history.identity(author).Name
+		if author.When.After(latest[name]) {
+			latest[name] = author.When
+		}
+	}
+	for _, owner := range authors {
+		owner.Percentage = math.Round(1000*float64(owner.Lines)/float64(len(owners))) / 10
+		if when, ok := latest[owner.Author]; ok {
+			owner.LastCommitDate = when.Format(time.RFC3339)
+		}
+	}
+	sort.Slice(authors, func(i, j int) bool {
+		if authors[i].Lines != authors[j].Lines {
+			return authors[i].Lines > authors[j].Lines
+		}
+		return authors[i].Author < authors[j].Author
+	})
+	ownership := make([]LineOwnership, len(authors))
+	for i, owner := range authors {
+		ownership[i] = *owner
+	}
+	return ownership, nil
+}
+
+// ownershipForFile returns the line ownership of a file, using the given histories
+func ownershipForFile(histories *gitHistories, path string) []LineOwnership {
+	history, relPath, err := histories.forPath(path)
+	if err != nil {
+		return nil
+	}
+	ownership, err := history.ownership(relPath)
+	if err != nil {
+		return nil
+	}
+	return ownership
+}
+
+// unchangedSinceHead checks if the given contents of a file are the same as the blob for the file in the HEAD commit
+func unchangedSinceHead(histories *gitHistories, path string, contents []byte) bool {
+	history, relPath, err := histories.forPath(path)
+	if err != nil || history.head.IsZero() {
+		return false
+	}
+	commit, ok := history.all[history.head]
+	if !ok {
+		return false
+	}
+	blob, ok := history.repo.blobAt(commit.tree, relPath)
+	return ok && blob == objectHash("blob", contents)
+}
+
+// GitOwnership reads the git history to find out how many of the current lines of a file each author last changed, like "git blame".
+// The lines are counted as they are in the HEAD commit.
+func GitOwnership(path string) ([]LineOwnership, error) {
+	histories := newGitHistories()
+	defer histories.Close()
+	history, relPath, err := histories.forPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the git history: %v", err)
+	}
+	return history.ownership(relPath)
+}
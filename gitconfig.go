@@ -0,0 +1,510 @@
+package projectinfo
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxGitConfigIncludeDepth limits how deeply include and includeIf directives are followed, like git does
+const maxGitConfigIncludeDepth = 10
+
+// gitConfigEntry is a variable in a git configuration file. The section and key are lowercase,
+// while the subsection is case sensitive, unless it was given in the deprecated [section.subsection] form.
+type gitConfigEntry struct {
+	section    string
+	subsection string
+	key        string
+	value      string
+}
+
+// GitConfig is a parsed git configuration, from one or more files
+type GitConfig struct {
+	entries []gitConfigEntry // in the order they were read, so that later values take precedence
+	gitDir  string           // the git directory, for includeIf "gitdir:" conditions
+	branch  string           // the current branch, for includeIf "onbranch:" conditions
+	skipped []string         // the lines that could not be parsed, as "file:line: reason"
+}
+
+// ParseGitConfig reads a git configuration file, following include directives.
+// The includeIf directives that depend on the repository are only followed if gitDir is not empty.
+// Lines that can not be parsed are skipped, and can be listed with SkippedLines.
+func ParseGitConfig(filename, gitDir string) (*GitConfig, error) {
+	config := newGitConfig(gitDir)
+	if err := config.readFile(filename, 0); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// newGitConfig creates an empty configuration for the given git directory, which may be empty
+func newGitConfig(gitDir string) *GitConfig {
+	config := &GitConfig{gitDir: gitDir}
+	if gitDir != "" {
+		if data, err := os.ReadFile(filepath.Join(gitDir, "HEAD")); err == nil {
+			if ref, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "ref:"); ok {
+				config.branch = strings.TrimPrefix(strings.TrimSpace(ref), "refs/heads/")
+			}
+		}
+	}
+	return config
+}
+
+// readFile parses a configuration file and adds its variables
+func (config *GitConfig) readFile(filename string, depth int) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return config.parse(string(data), filename, depth)
+}
+
+// parse parses the contents of a configuration file. The filename is used for relative include paths and in error messages.
+// A line that can not be parsed is recorded and skipped, so that one bad line does not hide the rest of the configuration.
+func (config *GitConfig) parse(data, filename string, depth int) error {
+	data = strings.TrimPrefix(data, "\ufeff") // a UTF-8 byte order mark
+	var section, subsection string
+	lineNumber := 1
+	skipLine := func(i int, reason string) int {
+		config.skipped = append(config.skipped, fmt.Sprintf("%s:%d: %s", filename, lineNumber, reason))
+		for i < len(data) && data[i] != '\n' {
+			i++
+		}
+		return i
+	}
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c == '\n':
+			lineNumber++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#' || c == ';':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+		case c == '[':
+			end, name, sub, err := parseGitConfigSection(data[i:])
+			if err != nil {
+				// The variables that follow do not belong to any known section
+				section, subsection = "", ""
+				i = skipLine(i, err.Error())
+				continue
+			}
+			section, subsection = name, sub
+			i += end
+		case isGitConfigKeyChar(c):
+			if section == "" {
+				i = skipLine(i, "variable outside of a section")
+				continue
+			}
+			start := i
+			for i < len(data) && isGitConfigKeyChar(data[i]) {
+				i++
+			}
+			key := strings.ToLower(data[start:i])
+			for i < len(data) && (data[i] == ' ' || data[i] == '\t') {
+				i++
+			}
+			value := "true" // a variable without a value is a true boolean
+			if i < len(data) && data[i] == '=' {
+				var n, lines int
+				value, n, lines = parseGitConfigValue(data[i+1:])
+				i += 1 + n
+				lineNumber += lines
+			}
+			config.entries = append(config.entries, gitConfigEntry{section, subsection, key, value})
+			if err := config.include(section, subsection, key, value, filename, depth); err != nil {
+				return err
+			}
+		default:
+			i = skipLine(i, fmt.Sprintf("unexpected %q", c))
+		}
+	}
+	return nil
+}
+
+// SkippedLines returns the lines that could not be parsed and were skipped, as "file:line: reason"
+func (config *GitConfig) SkippedLines() []string {
+	return config.skipped
+}
+
+// isGitConfigKeyChar checks if a byte can be part of a variable name
+func isGitConfigKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-'
+}
+
+// parseGitConfigSection parses a section header like [core], [remote "origin"] or [branch.main],
+// and returns the number of bytes it takes up
+func parseGitConfigSection(data string) (n int, section, subsection string, err error) {
+	i := 1
+	start := i
+	for i < len(data) && (isGitConfigKeyChar(data[i]) || data[i] == '.') {
+		i++
+	}
+	section = strings.ToLower(data[start:i])
+	if i < len(data) && data[i] == ']' {
+		// The deprecated [section.subsection] form has a lowercase subsection
+		if dot := strings.IndexByte(section, '.'); dot >= 0 {
+			section, subsection = section[:dot], section[dot+1:]
+		}
+		return i + 1, section, subsection, nil
+	}
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t') {
+		i++
+	}
+	if i >= len(data) || data[i] != '"' {
+		return 0, "", "", errors.New("invalid section header")
+	}
+	var sb strings.Builder
+	for i++; i < len(data) && data[i] != '"'; i++ {
+		if data[i] == '\n' {
+			return 0, "", "", errors.New("unterminated subsection name")
+		}
+		if data[i] == '\\' && i+1 < len(data) {
+			i++
+		}
+		sb.WriteByte(data[i])
+	}
+	if i+1 >= len(data) || data[i+1] != ']' {
+		return 0, "", "", errors.New("invalid section header")
+	}
+	return i + 2, section, sb.String(), nil
+}
+
+// parseGitConfigValue parses a value after the "=", up to the end of the line, handling quotes, escape sequences,
+// comments and line continuations. It returns the value, the number of bytes it takes up and the number of newlines in it.
+func parseGitConfigValue(data string) (value string, n, lines int) {
+	var (
+		sb      strings.Builder
+		spaces  strings.Builder // whitespace that is only kept if more of the value follows
+		inQuote bool
+		i       int
+	)
+	flush := func() {
+		if sb.Len() > 0 {
+			sb.WriteString(spaces.String())
+		}
+		spaces.Reset()
+	}
+	for ; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '\n':
+			return sb.String(), i, lines
+		case c == '\r' && i+1 < len(data) && data[i+1] == '\n':
+			continue
+		case c == '\\' && i+1 < len(data):
+			i++
+			switch data[i] {
+			case '\n':
+				lines++ // line continuation
+				continue
+			case '\r':
+				if i+1 < len(data) && data[i+1] == '\n' {
+					i++
+					lines++
+					continue
+				}
+				c = '\r'
+			case 'n':
+				c = '\n'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			default:
+				c = data[i]
+			}
+			flush()
+			sb.WriteByte(c)
+		case c == '"':
+			flush()
+			inQuote = !inQuote
+		case !inQuote && (c == '#' || c == ';'):
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			return sb.String(), i, lines
+		case !inQuote && (c == ' ' || c == '\t'):
+			spaces.WriteByte(c)
+		default:
+			flush()
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), i, lines
+}
+
+// include follows include.path and includeIf.<condition>.path variables
+func (config *GitConfig) include(section, subsection, key, value, filename string, depth int) error {
+	if key != "path" || value == "" {
+		return nil
+	}
+	switch {
+	case section == "include" && subsection == "":
+	case section == "includeif" && config.includeCondition(subsection, filename):
+	default:
+		return nil
+	}
+	if depth >= maxGitConfigIncludeDepth {
+		return fmt.Errorf("%s: too many levels of includes", filename)
+	}
+	path := expandHome(value)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(filename), path)
+	}
+	if err := config.readFile(path, depth+1); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// includeCondition checks if the condition of an includeIf section is true
+func (config *GitConfig) includeCondition(condition, filename string) bool {
+	kind, pattern, found := strings.Cut(condition, ":")
+	if !found || pattern == "" {
+		return false
+	}
+	switch kind {
+	case "gitdir", "gitdir/i":
+		if config.gitDir == "" {
+			return false
+		}
+		pattern = expandHome(pattern)
+		if rest, ok := strings.CutPrefix(pattern, "./"); ok {
+			pattern = filepath.ToSlash(filepath.Dir(filename)) + "/" + rest
+		}
+		if !strings.HasPrefix(pattern, "/") && !filepath.IsAbs(pattern) {
+			pattern = "**/" + pattern
+		}
+		return matchGitConfigGlob(filepath.ToSlash(pattern), filepath.ToSlash(config.gitDir), kind == "gitdir/i")
+	case "onbranch":
+		return config.branch != "" && matchGitConfigGlob(pattern, config.branch, false)
+	}
+	return false
+}
+
+// matchGitConfigGlob matches a path against an includeIf pattern, where a trailing slash matches everything inside a directory
+func matchGitConfigGlob(pattern, s string, ignoreCase bool) bool {
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	expr := "^" + globToRegexp(pattern) + "$"
+	if ignoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	return err == nil && re.MatchString(s)
+}
+
+// expandHome replaces a leading "~/" with the home directory of the user
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+// splitGitConfigName splits a name like "remote.origin.url" into a lowercase section, a subsection and a lowercase key
+func splitGitConfigName(name string) (section, subsection, key string) {
+	first := strings.IndexByte(name, '.')
+	last := strings.LastIndexByte(name, '.')
+	if first < 0 {
+		return strings.ToLower(name), "", ""
+	}
+	section, key = strings.ToLower(name[:first]), strings.ToLower(name[last+1:])
+	if first < last {
+		subsection = name[first+1 : last]
+	}
+	return section, subsection, key
+}
+
+// GetAll returns all values of a variable like "remote.origin.fetch", in the order they were read
+func (config *GitConfig) GetAll(name string) []string {
+	section, subsection, key := splitGitConfigName(name)
+	var values []string
+	for _, entry := range config.entries {
+		if entry.section == section && entry.subsection == subsection && entry.key == key {
+			values = append(values, entry.value)
+		}
+	}
+	return values
+}
+
+// Get returns the last value of a variable like "remote.origin.url", or "" if it is not set
+func (config *GitConfig) Get(name string) string {
+	values := config.GetAll(name)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+// Subsections returns the subsections of a section, like the names of all remotes for "remote", in the order they first appear
+func (config *GitConfig) Subsections(section string) []string {
+	section = strings.ToLower(section)
+	seen := make(map[string]bool)
+	var names []string
+	for _, entry := range config.entries {
+		if entry.section == section && entry.subsection != "" && !seen[entry.subsection] {
+			seen[entry.subsection] = true
+			names = append(names, entry.subsection)
+		}
+	}
+	return names
+}
+
+// globalGitConfigFiles returns the global configuration files of the user, with the one that takes precedence last
+func globalGitConfigFiles() []string {
+	if filename := os.Getenv("GIT_CONFIG_GLOBAL"); filename != "" {
+		return []string{filename}
+	}
+	home, _ := os.UserHomeDir()
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" && home != "" {
+		configHome = filepath.Join(home, ".config")
+	}
+	var filenames []string
+	if configHome != "" {
+		filenames = append(filenames, filepath.Join(configHome, "git", "config"))
+	}
+	if home != "" {
+		filenames = append(filenames, filepath.Join(home, ".gitconfig")) // takes precedence over the XDG configuration file
+	}
+	return filenames
+}
+
+// readGlobalGitConfig reads the global configuration files of the user, if there are any
+func readGlobalGitConfig() *GitConfig {
+	config := newGitConfig("")
+	for _, filename := range globalGitConfigFiles() {
+		config.readFile(filename, 0) // a missing or invalid global configuration is the same as an empty one
+	}
+	return config
+}
+
+// readCommonDir returns the directory with the shared configuration, objects and references of a git directory,
+// which differs from the git directory itself for linked worktrees
+func readCommonDir(gitDir string) string {
+	data, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+	commonDir := strings.TrimSpace(string(data))
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(gitDir, commonDir)
+	}
+	return filepath.Clean(commonDir)
+}
+
+// readRepoGitConfig reads the global configuration of the user, followed by the configuration of the repository
+// in the given git directory and, if enabled, the worktree specific configuration
+func readRepoGitConfig(gitDir string) (*GitConfig, error) {
+	config := newGitConfig(gitDir)
+	for _, filename := range globalGitConfigFiles() {
+		config.readFile(filename, 0)
+	}
+	if err := config.readFile(filepath.Join(readCommonDir(gitDir), "config"), 0); err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(config.Get("extensions.worktreeConfig"), "true") {
+		if err := config.readFile(filepath.Join(gitDir, "config.worktree"), 0); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// GitRemote is a remote repository, as configured in the git configuration
+type GitRemote struct {
+	Name     string   `json:"name"`
+	URLs     []string `json:"urls"`
+	PushURLs []string `json:"pushURLs,omitempty"` // only set if they differ from the fetch URLs
+}
+
+// Remotes returns all configured remotes, sorted by name but with "origin" first.
+// The url.<base>.insteadOf and pushInsteadOf rewrites are applied, and passwords and tokens are removed from the URLs.
+func (config *GitConfig) Remotes() []GitRemote {
+	var remotes []GitRemote
+	for _, name := range config.Subsections("remote") {
+		remote := GitRemote{Name: name}
+		for _, url := range config.GetAll("remote." + name + ".url") {
+			remote.URLs = append(remote.URLs, stripCredentials(config.rewriteURL(url, "insteadof")))
+		}
+		for _, url := range config.GetAll("remote." + name + ".pushurl") {
+			remote.PushURLs = append(remote.PushURLs, stripCredentials(config.rewriteURL(url, "insteadof")))
+		}
+		if len(remote.PushURLs) == 0 {
+			// Without push URLs, the URLs are used for pushing too, but with pushInsteadOf rewrites
+			for _, url := range config.GetAll("remote." + name + ".url") {
+				if rewritten := config.rewriteURL(url, "pushinsteadof"); rewritten != url {
+					remote.PushURLs = append(remote.PushURLs, stripCredentials(rewritten))
+				}
+			}
+		}
+		if len(remote.URLs) > 0 || len(remote.PushURLs) > 0 {
+			remotes = append(remotes, remote)
+		}
+	}
+	sort.SliceStable(remotes, func(i, j int) bool {
+		if (remotes[i].Name == "origin") != (remotes[j].Name == "origin") {
+			return remotes[i].Name == "origin"
+		}
+		return remotes[i].Name < remotes[j].Name
+	})
+	return remotes
+}
+
+// rewriteURL applies the url.<base>.insteadOf (or pushInsteadOf) setting with the longest matching prefix
+func (config *GitConfig) rewriteURL(url, key string) string {
+	var base, prefix string
+	for _, entry := range config.entries {
+		if entry.section == "url" && entry.key == key && strings.HasPrefix(url, entry.value) && len(entry.value) > len(prefix) {
+			base, prefix = entry.subsection, entry.value
+		}
+	}
+	if prefix == "" {
+		return url
+	}
+	return base + strings.TrimPrefix(url, prefix)
+}
+
+// GitRepoInfo is what the git configuration and HEAD say about a repository
+type GitRepoInfo struct {
+	WorkTree   string // the root directory of the checked out files
+	Remotes    []GitRemote
+	Branch     string // the current branch, or "" if HEAD is detached
+	HeadCommit string // the commit that HEAD points to, or "" if there are no commits
+}
+
+// ReadGitRepoInfo reads the remotes, the current branch and the HEAD commit of the git repository that contains the given path.
+// A .git file that points to the git directory, as used by worktrees and submodules, is followed.
+func ReadGitRepoInfo(path string) (GitRepoInfo, error) {
+	gitDir, workTree, err := findGitDir(path)
+	if err != nil {
+		return GitRepoInfo{}, err
+	}
+	config, err := readRepoGitConfig(gitDir)
+	if err != nil {
+		return GitRepoInfo{}, err
+	}
+	info := GitRepoInfo{WorkTree: workTree, Remotes: config.Remotes(), Branch: config.branch}
+	repo, err := openGitRepository(path)
+	if err != nil {
+		return info, err
+	}
+	defer repo.Close()
+	if h, err := repo.readRef("HEAD"); err == nil {
+		info.HeadCommit = h.String()
+	}
+	return info, nil
+}
@@ -1,67 +1,93 @@
 package projectinfo
 
 import (
-	"bufio"
 	"fmt"
-	"os/exec"
-	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 )
 
-// TODO: Use a Go module instead of the git command
+// Contributor is an author of commits in a git repository, with statistics about the commits.
+// The name and email address are the canonical ones, after applying .mailmap and possibly merging identities.
+// LinesAdded and LinesRemoved are only counted by GitContributorDetails, or with Options.LineStats, and are left out of the JSON otherwise.
+type Contributor struct {
+	Name         string    `json:"name"`
+	Email        string    `json:"email,omitempty"` // the canonical email address, which is used for most of the commits
+	Emails       []string  `json:"emails"`
+	Aliases      []string  `json:"aliases,omitempty"` // the other names and email addresses that were used for the commits, like "Jane <jane@old.example.com>"
+	Commits      int       `json:"commits"`
+	FirstCommit  time.Time `json:"firstCommit"`
+	LastCommit   time.Time `json:"lastCommit"`
+	LinesAdded   int       `json:"linesAdded,omitempty"`   // only counted by GitContributorDetails, or with Options.LineStats
+	LinesRemoved int       `json:"linesRemoved,omitempty"` // only counted by GitContributorDetails, or with Options.LineStats
+	Bot          bool      `json:"bot,omitempty"`          // the name or an email address matches a bot pattern
+}
 
-// maybeGitContriburorsForFile returns a slice of contributors for a given file or directory
-func maybeGitContributorsForFile(path string) []string {
-	dir := path
-	if !isDir(path) {
-		dir = filepath.Dir(path)
-	}
-	cmd := exec.Command("git", "shortlog", "-sn", "--all", "--no-merges", path)
-	cmd.Dir = dir
-	output, err := cmd.Output()
+// contributorsForFile returns a slice of contributors for a given file or directory, using the given histories
+func contributorsForFile(histories *gitHistories, path string) []string {
+	history, relPath, err := histories.forPath(path)
 	if err != nil {
 		return []string{}
 	}
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
-	var contributors []string
-	var line string
-	for scanner.Scan() {
-		line = scanner.Text()
-		if name := ParseContributor(line); name != "" {
-			contributors = append(contributors, name)
-		}
+	return history.contributors(relPath)
+}
+
+// lastCommitForFile returns the last commit that changed a file and the canonical name of its author, using the given histories.
+// The commit is nil if the file has not been committed.
+func lastCommitForFile(histories *gitHistories, path string) (*gitCommit, string) {
+	history, relPath, err := histories.forPath(path)
+	if err != nil {
+		return nil, ""
 	}
-	if err := scanner.Err(); err != nil {
-		return []string{}
+	indices := history.byPath[relPath]
+	if len(indices) == 0 {
+		return nil, ""
 	}
-	return contributors
+	commit := history.commits[indices[0]] // the commits are sorted newest first
+	return commit, history.identity(commit.author).Name
 }
 
-// Contributors uses the Git command line to fetch a list of contributors sorted by the number of commits
+// GitContributors reads the git history to fetch a list of contributors sorted by the number of commits, like "git shortlog -sn --all --no-merges".
+// The repository is read directly, so the git command does not need to be installed.
 func GitContributors(path string) ([]string, error) {
-	dir := path
-	if !isDir(path) {
-		dir = filepath.Dir(path)
-	}
-	cmd := exec.Command("git", "shortlog", "-sn", "--all", "--no-merges")
-	cmd.Dir = dir
-	output, err := cmd.Output()
+	histories := newGitHistories()
+	defer histories.Close()
+	history, _, err := histories.forPath(path)
 	if err != nil {
-		return []string{}, fmt.Errorf("failed to execute git command: %v", err)
+		return []string{}, fmt.Errorf("failed to read the git history: %v", err)
 	}
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
-	var contributors []string
-	var line string
-	for scanner.Scan() {
-		line = scanner.Text()
-		if name := ParseContributor(line); name != "" {
-			contributors = append(contributors, name)
-		}
+	return history.contributors(""), nil
+}
+
+// GitContributorDetails reads the git history to fetch the contributors of the repository that contains path,
+// with their email addresses, commit counts, first and last commit dates and the number of lines they added and removed.
+// Contributors that match DefaultBotPatterns are marked as bots.
+// The contributors are sorted by the number of commits, like "git shortlog -sne --all --no-merges".
+func GitContributorDetails(path string) ([]Contributor, error) {
+	histories := newGitHistories()
+	defer histories.Close()
+	contributors, err := gitContributors(histories, path, true)
+	markBots(contributors, defaultBotDetector)
+	return contributors, err
+}
+
+// gitContributors fetches the contributors of the repository that contains path, using the given histories.
+// The lines each contributor added and removed are only counted if lineStats is true, since that needs a diff of every changed file in every commit.
+func gitContributors(histories *gitHistories, path string, lineStats bool) ([]Contributor, error) {
+	history, _, err := histories.forPath(path)
+	if err != nil {
+		return []Contributor{}, fmt.Errorf("failed to read the git history: %w", err)
 	}
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error scanning git output: %v", err)
+	return history.contributorRecords("", lineStats), nil
+}
+
+// contributorNames returns the names of the given contributors
+func contributorNames(contributors []Contributor) []string {
+	names := make([]string, len(contributors))
+	for i, contributor := range contributors {
+		names[i] = contributor.Name
 	}
-	return contributors, nil
+	return names
 }
 
 // parseContributor extracts the name of the contributor from a line of git shortlog output.
@@ -72,3 +98,22 @@ func ParseContributor(line string) string {
 	}
 	return parts[1]
 }
+
+// ParseContributorLine parses a line of "git shortlog -sn" or "git shortlog -sne" output, like "    42\tJane Doe <jane@example.com>",
+// into a Contributor with the name, commit count and email address, if there is one.
+func ParseContributorLine(line string) (Contributor, bool) {
+	count, rest, found := strings.Cut(strings.TrimSpace(line), "\t")
+	if !found {
+		return Contributor{}, false
+	}
+	commits, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil {
+		return Contributor{}, false
+	}
+	contributor := Contributor{Name: strings.TrimSpace(rest), Commits: commits}
+	if lt := strings.LastIndex(rest, "<"); lt >= 0 && strings.HasSuffix(rest, ">") {
+		contributor.Name = strings.TrimSpace(rest[:lt])
+		contributor.Emails = []string{rest[lt+1 : len(rest)-1]}
+	}
+	return contributor, true
+}
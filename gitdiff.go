@@ -0,0 +1,113 @@
+package projectinfo
+
+import "strings"
+
+// maxDiffEdits is the largest number of line edits matchLines searches for, before it gives up on aligning
+// the middle of two very different files and treats it as completely replaced
+const maxDiffEdits = 2000
+
+// splitLines splits file contents into lines, keeping the line endings
+func splitLines(contents string) []string {
+	lines := strings.SplitAfter(contents, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// matchLines returns the pairs of line indices in a and b that are unchanged, in increasing order.
+// The pairs form a longest common subsequence, found with the Myers diff algorithm.
+func matchLines(a, b []string) [][2]int {
+	var matches [][2]int
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		matches = append(matches, [2]int{prefix, prefix})
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	middle, _ := myersMatches(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], maxDiffEdits)
+	for _, match := range middle {
+		matches = append(matches, [2]int{match[0] + prefix, match[1] + prefix})
+	}
+	for i := suffix; i > 0; i-- {
+		matches = append(matches, [2]int{len(a) - i, len(b) - i})
+	}
+	return matches
+}
+
+// diffStat returns the number of lines that are added and removed when going from a to b
+func diffStat(a, b []string) (added, removed int) {
+	unchanged := len(matchLines(a, b))
+	return len(b) - unchanged, len(a) - unchanged
+}
+
+// myersMatches finds the unchanged lines between a and b with the Myers algorithm.
+// It returns false if more than maxEdits edits are needed.
+func myersMatches(a, b []string, maxEdits int) ([][2]int, bool) {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return nil, true
+	}
+	offset := maxEdits + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int // the furthest reaching x for each diagonal k in [-d-1, d+1], after step d
+	snapshot := func(d int) []int {
+		return append([]int(nil), v[offset-d-1:offset+d+2]...)
+	}
+	for d := 0; d <= maxEdits; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // move down, inserting a line from b
+			} else {
+				x = v[offset+k-1] + 1 // move right, removing a line from a
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				trace = append(trace, snapshot(d))
+				return backtrackMatches(trace, n, m), true
+			}
+		}
+		trace = append(trace, snapshot(d))
+	}
+	return nil, false
+}
+
+// backtrackMatches follows the trace of myersMatches backwards from the end, collecting the diagonal moves
+func backtrackMatches(trace [][]int, n, m int) [][2]int {
+	var matches [][2]int
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1] // index k+d is diagonal k
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && prev[k-1+d] < prev[k+1+d]) {
+			prevK = k + 1
+		}
+		prevX := prev[prevK+d]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			matches = append(matches, [2]int{x, y})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		matches = append(matches, [2]int{x, y})
+	}
+	for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+		matches[i], matches[j] = matches[j], matches[i]
+	}
+	return matches
+}
@@ -0,0 +1,547 @@
+package projectinfo
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gitSignature is the author or committer of a commit
+type gitSignature struct {
+	Name  string
+	Email string
+	When  time.Time
+}
+
+// gitCommit is a parsed commit object
+type gitCommit struct {
+	hash      gitHash
+	tree      gitHash
+	parents   []gitHash
+	author    gitSignature
+	committer gitSignature
+	changes   []gitFileChange // files changed compared to the parent, only for non-merge commits
+	statsOnce sync.Once       // guards the line counts of the changes, which are counted when first needed
+}
+
+// gitFileChange is a file that was added, modified or deleted by a commit
+type gitFileChange struct {
+	path    string // slash separated, relative to the root of the repository
+	oldBlob gitHash
+	newBlob gitHash
+	oldMode string
+	newMode string
+	added   int // lines added, once countLines has been called on the commit
+	removed int // lines removed, once countLines has been called on the commit
+}
+
+// gitSubmoduleMode is the mode of a tree entry for a submodule, where the hash is a commit in another repository
+const gitSubmoduleMode = "160000"
+
+// gitTreeEntry is a file, directory, symlink or submodule in a tree object
+type gitTreeEntry struct {
+	mode string
+	name string
+	hash gitHash
+}
+
+// isTree checks if the entry is a directory
+func (entry gitTreeEntry) isTree() bool {
+	return entry.mode == "40000" || entry.mode == "040000"
+}
+
+// parseGitSignature parses a signature like "Jane Doe <jane@example.com> 1700000000 +0100"
+func parseGitSignature(s string) gitSignature {
+	var sig gitSignature
+	lt := strings.Index(s, "<")
+	gt := strings.LastIndex(s, ">")
+	if lt < 0 || gt < lt {
+		sig.Name = strings.TrimSpace(s)
+		return sig
+	}
+	sig.Name = strings.TrimSpace(s[:lt])
+	sig.Email = s[lt+1 : gt]
+	fields := strings.Fields(s[gt+1:])
+	if len(fields) > 0 {
+		if seconds, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+			loc := time.UTC
+			if len(fields) > 1 {
+				if tz, err := time.Parse("-0700", fields[1]); err == nil {
+					loc = tz.Location()
+				}
+			}
+			sig.When = time.Unix(seconds, 0).In(loc)
+		}
+	}
+	return sig
+}
+
+// readCommit reads and parses a commit object
+func (repo *gitRepository) readCommit(h gitHash) (*gitCommit, error) {
+	kind, data, err := repo.readObject(h)
+	if err != nil {
+		return nil, err
+	}
+	if kind != gitObjectCommit {
+		return nil, fmt.Errorf("object %s is not a commit", h)
+	}
+	commit := &gitCommit{hash: h}
+	header, _, _ := bytes.Cut(data, []byte("\n\n"))
+	for _, line := range strings.Split(string(header), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "tree":
+			if commit.tree, err = parseGitHash(value); err != nil {
+				return nil, err
+			}
+		case "parent":
+			parent, err := parseGitHash(value)
+			if err != nil {
+				return nil, err
+			}
+			commit.parents = append(commit.parents, parent)
+		case "author":
+			commit.author = parseGitSignature(value)
+		case "committer":
+			commit.committer = parseGitSignature(value)
+		}
+	}
+	if repo.shallow[h] {
+		commit.parents = nil // the parents are not available in a shallow clone
+	}
+	return commit, nil
+}
+
+// readTree reads and parses a tree object. The zero hash gives an empty tree.
+func (repo *gitRepository) readTree(h gitHash) ([]gitTreeEntry, error) {
+	if h.IsZero() {
+		return nil, nil
+	}
+	kind, data, err := repo.readObject(h)
+	if err != nil {
+		return nil, err
+	}
+	if kind != gitObjectTree {
+		return nil, fmt.Errorf("object %s is not a tree", h)
+	}
+	var entries []gitTreeEntry
+	for len(data) > 0 {
+		space := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+		if space < 0 || nul < space || nul+21 > len(data) {
+			return nil, fmt.Errorf("invalid tree object %s", h)
+		}
+		entry := gitTreeEntry{mode: string(data[:space]), name: string(data[space+1 : nul])}
+		copy(entry.hash[:], data[nul+1:nul+21])
+		entries = append(entries, entry)
+		data = data[nul+21:]
+	}
+	return entries, nil
+}
+
+// diffTrees returns the files that differ between two trees, where either tree can be the zero hash for an empty tree
+func (repo *gitRepository) diffTrees(prefix string, oldTree, newTree gitHash) ([]gitFileChange, error) {
+	if oldTree == newTree {
+		return nil, nil
+	}
+	oldEntries, err := repo.readTree(oldTree)
+	if err != nil {
+		return nil, err
+	}
+	newEntries, err := repo.readTree(newTree)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string][2]*gitTreeEntry)
+	var names []string
+	for i := range oldEntries {
+		pair := byName[oldEntries[i].name]
+		if pair[0] == nil && pair[1] == nil {
+			names = append(names, oldEntries[i].name)
+		}
+		pair[0] = &oldEntries[i]
+		byName[oldEntries[i].name] = pair
+	}
+	for i := range newEntries {
+		pair := byName[newEntries[i].name]
+		if pair[0] == nil && pair[1] == nil {
+			names = append(names, newEntries[i].name)
+		}
+		pair[1] = &newEntries[i]
+		byName[newEntries[i].name] = pair
+	}
+	sort.Strings(names)
+
+	var changes []gitFileChange
+	for _, name := range names {
+		pair := byName[name]
+		oldEntry, newEntry := pair[0], pair[1]
+		path := prefix + name
+		var oldSub, newSub gitHash // subtrees to compare
+		var change gitFileChange
+		hasChange := false
+		if oldEntry != nil {
+			if oldEntry.isTree() {
+				oldSub = oldEntry.hash
+			} else {
+				change.oldBlob, change.oldMode = oldEntry.hash, oldEntry.mode
+				hasChange = true
+			}
+		}
+		if newEntry != nil {
+			if newEntry.isTree() {
+				newSub = newEntry.hash
+			} else {
+				change.newBlob, change.newMode = newEntry.hash, newEntry.mode
+				hasChange = true
+			}
+		}
+		if oldSub != newSub {
+			subChanges, err := repo.diffTrees(path+"/", oldSub, newSub)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, subChanges...)
+		}
+		if hasChange && (change.oldBlob != change.newBlob || change.oldMode != change.newMode) {
+			change.path = path
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// gitHistory is the result of walking the history of all references of a repository once
+type gitHistory struct {
+	repo    *gitRepository
+	head    gitHash                // the commit HEAD points to, or the zero hash if there are no commits
+	all     map[gitHash]*gitCommit // all reachable commits, including merges
+	commits []*gitCommit           // non-merge commits, newest first
+	byPath  map[string][]int       // indices into commits, for the commits that changed each file
+
+	identities map[gitIdentity]gitIdentity // the canonical identity of each author, after applying .mailmap and merging
+}
+
+// loadGitHistory walks all commits that are reachable from HEAD and the references of the repository,
+// and finds the files that are changed by each non-merge commit
+func loadGitHistory(repo *gitRepository) (*gitHistory, error) {
+	tips, err := repo.tips()
+	if err != nil {
+		return nil, err
+	}
+	all := make(map[gitHash]*gitCommit)
+	stack := append([]gitHash(nil), tips...)
+	for len(stack) > 0 {
+		h := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if _, seen := all[h]; seen {
+			continue
+		}
+		commit, err := repo.readCommit(h)
+		if err != nil {
+			return nil, err
+		}
+		all[h] = commit
+		stack = append(stack, commit.parents...)
+	}
+
+	history := &gitHistory{repo: repo, all: all, byPath: make(map[string][]int)}
+	if h, err := repo.readRef("HEAD"); err == nil {
+		if h, err = repo.peel(h); err == nil {
+			history.head = h
+		}
+	}
+	for _, commit := range all {
+		if len(commit.parents) > 1 {
+			continue // like --no-merges
+		}
+		var parentTree gitHash
+		if len(commit.parents) == 1 {
+			parentTree = all[commit.parents[0]].tree
+		}
+		if commit.changes, err = repo.diffTrees("", parentTree, commit.tree); err != nil {
+			return nil, err
+		}
+		history.commits = append(history.commits, commit)
+	}
+	sort.Slice(history.commits, func(i, j int) bool {
+		a, b := history.commits[i], history.commits[j]
+		if !a.committer.When.Equal(b.committer.When) {
+			return a.committer.When.After(b.committer.When)
+		}
+		return bytes.Compare(a.hash[:], b.hash[:]) < 0
+	})
+	for i, commit := range history.commits {
+		for _, change := range commit.changes {
+			history.byPath[change.path] = append(history.byPath[change.path], i)
+		}
+	}
+	return history, nil
+}
+
+// resolveIdentities finds the canonical identity of every author, using the given mailmap and,
+// if merge is true, by merging identities that look like the same person
+func (history *gitHistory) resolveIdentities(mailmap *Mailmap, merge bool) {
+	counts := make(map[gitIdentity]int)
+	for _, commit := range history.commits {
+		counts[gitIdentity{commit.author.Name, commit.author.Email}]++
+	}
+	history.identities = resolveIdentities(counts, mailmap, merge)
+}
+
+// identity returns the canonical identity of the author of a commit
+func (history *gitHistory) identity(sig gitSignature) gitIdentity {
+	raw := gitIdentity{sig.Name, sig.Email}
+	if identity, ok := history.identities[raw]; ok {
+		return identity
+	}
+	return raw
+}
+
+// countLines counts the lines added and removed by each change of a commit, the first time it is called.
+// Binary files and submodules count as no lines.
+func (repo *gitRepository) countLines(commit *gitCommit) {
+	commit.statsOnce.Do(func() {
+		for i := range commit.changes {
+			change := &commit.changes[i]
+			oldLines, okOld := repo.textLines(change.oldBlob, change.oldMode)
+			newLines, okNew := repo.textLines(change.newBlob, change.newMode)
+			if okOld && okNew {
+				change.added, change.removed = diffStat(oldLines, newLines)
+			}
+		}
+	})
+}
+
+// textLines reads the lines of a blob, if it is a text file. The zero hash gives no lines.
+func (repo *gitRepository) textLines(h gitHash, mode string) ([]string, bool) {
+	if h.IsZero() {
+		return nil, true
+	}
+	if mode == gitSubmoduleMode {
+		return nil, false
+	}
+	kind, data, err := repo.readObject(h)
+	if err != nil || kind != gitObjectBlob || bytes.IndexByte(data, 0) >= 0 {
+		return nil, false
+	}
+	return splitLines(string(data)), true
+}
+
+// commitsFor returns the indices of the non-merge commits that changed the given file, or any file in the given directory, newest first.
+// An empty path or "." gives all non-merge commits.
+func (history *gitHistory) commitsFor(relPath string) []int {
+	if relPath == "" || relPath == "." {
+		indices := make([]int, len(history.commits))
+		for i := range indices {
+			indices[i] = i
+		}
+		return indices
+	}
+	if indices, ok := history.byPath[relPath]; ok {
+		return indices
+	}
+	seen := make(map[int]bool)
+	prefix := relPath + "/"
+	for path, indices := range history.byPath {
+		if strings.HasPrefix(path, prefix) {
+			for _, i := range indices {
+				seen[i] = true
+			}
+		}
+	}
+	indices := make([]int, 0, len(seen))
+	for i := range seen {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+	return indices
+}
+
+// inPath checks if a slash separated file path is the given path, or is inside of it.
+// An empty path or "." contains all files.
+func inPath(filePath, relPath string) bool {
+	return relPath == "" || relPath == "." || filePath == relPath || strings.HasPrefix(filePath, relPath+"/")
+}
+
+// contributors returns the names of the authors of the non-merge commits that changed the given path,
+// sorted by the number of commits, like "git shortlog -sn --all --no-merges <path>"
+func (history *gitHistory) contributors(relPath string) []string {
+	records := history.contributorRecords(relPath, false)
+	names := make([]string, len(records))
+	for i, record := range records {
+		names[i] = record.Name
+	}
+	return names
+}
+
+// contributorRecords returns the authors of the non-merge commits that changed the given path, sorted by the number of commits.
+// Authors are grouped by their canonical name. If lineStats is true, the lines each author added and removed in the given path are counted too.
+func (history *gitHistory) contributorRecords(relPath string, lineStats bool) []Contributor {
+	type record struct {
+		Contributor
+		emails  map[string]int // commits per canonical email address, and 0 for the addresses that were used in the commits
+		aliases map[string]bool
+	}
+	byName := make(map[string]*record)
+	var records []*record
+	for _, i := range history.commitsFor(relPath) {
+		commit := history.commits[i]
+		author := commit.author
+		identity := history.identity(author)
+		r, ok := byName[identity.Name]
+		if !ok {
+			r = &record{Contributor: Contributor{Name: identity.Name}, emails: make(map[string]int), aliases: make(map[string]bool)}
+			byName[identity.Name] = r
+			records = append(records, r)
+		}
+		r.Commits++
+		if identity.Email != "" {
+			r.emails[identity.Email]++
+		}
+		if _, seen := r.emails[author.Email]; !seen && author.Email != "" {
+			r.emails[author.Email] = 0
+		}
+		if raw := (gitIdentity{author.Name, author.Email}); raw != identity {
+			r.aliases[raw.String()] = true
+		}
+		if r.FirstCommit.IsZero() || author.When.Before(r.FirstCommit) {
+			r.FirstCommit = author.When
+		}
+		if author.When.After(r.LastCommit) {
+			r.LastCommit = author.When
+		}
+		if lineStats {
+			history.repo.countLines(commit)
+			for _, change := range commit.changes {
+				if inPath(change.path, relPath) {
+					r.LinesAdded += change.added
+					r.LinesRemoved += change.removed
+				}
+			}
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Commits != records[j].Commits {
+			return records[i].Commits > records[j].Commits
+		}
+		return records[i].Name < records[j].Name
+	})
+	contributors := make([]Contributor, len(records))
+	for i, r := range records {
+		for email, count := range r.emails {
+			r.Emails = append(r.Emails, email)
+			if best := r.emails[r.Email]; r.Email == "" || count > best || (count == best && email < r.Email) {
+				r.Email = email
+			}
+		}
+		sort.Strings(r.Emails)
+		for alias := range r.aliases {
+			r.Aliases = append(r.Aliases, alias)
+		}
+		sort.Strings(r.Aliases)
+		contributors[i] = r.Contributor
+	}
+	return contributors
+}
+
+// gitHistories opens each repository and walks its history only once, no matter how many files are looked up in it
+type gitHistories struct {
+	mergeIdentities bool // merge authors that look like the same person, in addition to applying .mailmap
+
+	mut       sync.Mutex
+	workTrees map[string]string // directory to work tree, for the directories that have been looked up
+	byRoot    map[string]*gitHistoryEntry
+}
+
+// gitHistoryEntry is the history of one repository, which is loaded the first time it is needed
+type gitHistoryEntry struct {
+	once    sync.Once
+	history *gitHistory
+	err     error
+}
+
+// newGitHistories creates an empty cache of repository histories
+func newGitHistories() *gitHistories {
+	return &gitHistories{workTrees: make(map[string]string), byRoot: make(map[string]*gitHistoryEntry)}
+}
+
+// forPath returns the history of the repository that contains the given path, and the path relative to the root of that repository
+func (histories *gitHistories) forPath(path string) (*gitHistory, string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, "", err
+	}
+	dir := abs
+	if !isDir(abs) {
+		dir = filepath.Dir(abs)
+	}
+
+	histories.mut.Lock()
+	workTree, ok := histories.workTrees[dir]
+	histories.mut.Unlock()
+	if !ok {
+		_, workTree, err = findGitDir(dir)
+		if err != nil {
+			return nil, "", err
+		}
+		histories.mut.Lock()
+		histories.workTrees[dir] = workTree
+		histories.mut.Unlock()
+	}
+
+	histories.mut.Lock()
+	entry, ok := histories.byRoot[workTree]
+	if !ok {
+		entry = &gitHistoryEntry{}
+		histories.byRoot[workTree] = entry
+	}
+	histories.mut.Unlock()
+
+	entry.once.Do(func() {
+		repo, err := openGitRepository(workTree)
+		if err != nil {
+			entry.err = err
+			return
+		}
+		// The failed result is kept for good, so the repository is closed here, since Close only closes loaded histories
+		history, err := loadGitHistory(repo)
+		if err != nil {
+			repo.Close()
+			entry.err = err
+			return
+		}
+		mailmap, err := LoadMailmap(filepath.Join(repo.workTree, ".mailmap"))
+		if err != nil {
+			repo.Close()
+			entry.err = err
+			return
+		}
+		history.resolveIdentities(mailmap, histories.mergeIdentities)
+		entry.history = history
+	})
+	if entry.err != nil {
+		return nil, "", entry.err
+	}
+	rel, err := filepath.Rel(workTree, abs)
+	if err != nil {
+		return nil, "", err
+	}
+	return entry.history, filepath.ToSlash(rel), nil
+}
+
+// Close closes the repositories that have been opened
+func (histories *gitHistories) Close() {
+	histories.mut.Lock()
+	defer histories.mut.Unlock()
+	for _, entry := range histories.byRoot {
+		if entry.history != nil {
+			entry.history.repo.Close()
+		}
+	}
+}
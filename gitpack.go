@@ -0,0 +1,269 @@
+package projectinfo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Object types that only appear in pack files
+const (
+	gitObjectOffsetDelta = 6
+	gitObjectRefDelta    = 7
+)
+
+// gitPack is a pack file together with its version 2 index
+type gitPack struct {
+	file         *os.File
+	fanout       [256]uint32
+	hashes       []byte // sorted object IDs, 20 bytes each
+	offsets      []byte // 4 bytes per object
+	largeOffsets []byte // 8 bytes per object with an offset that does not fit in 31 bits
+}
+
+// openGitPacks opens all pack files in the given directory
+func openGitPacks(packDir string) ([]*gitPack, error) {
+	indexFiles, err := filepath.Glob(filepath.Join(packDir, "pack-*.idx"))
+	if err != nil {
+		return nil, err
+	}
+	var packs []*gitPack
+	for _, indexFile := range indexFiles {
+		pack, err := openGitPack(indexFile, strings.TrimSuffix(indexFile, ".idx")+".pack")
+		if err != nil {
+			for _, pack := range packs {
+				pack.Close()
+			}
+			return nil, fmt.Errorf("%s: %v", indexFile, err)
+		}
+		packs = append(packs, pack)
+	}
+	return packs, nil
+}
+
+// openGitPack reads a pack index and opens the corresponding pack file
+func openGitPack(indexFile, packFile string) (*gitPack, error) {
+	idx, err := os.ReadFile(indexFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(idx) < 8+256*4 || !bytes.Equal(idx[:4], []byte("\xfftOc")) || binary.BigEndian.Uint32(idx[4:8]) != 2 {
+		return nil, errors.New("only version 2 pack indexes are supported")
+	}
+	pack := &gitPack{}
+	for i := range pack.fanout {
+		pack.fanout[i] = binary.BigEndian.Uint32(idx[8+4*i:])
+	}
+	n := int(pack.fanout[255])
+	hashesStart := 8 + 256*4
+	offsetsStart := hashesStart + 20*n + 4*n // skip the CRC32 values
+	largeOffsetsStart := offsetsStart + 4*n
+	if len(idx) < largeOffsetsStart {
+		return nil, errors.New("truncated pack index")
+	}
+	pack.hashes = idx[hashesStart : hashesStart+20*n]
+	pack.offsets = idx[offsetsStart:largeOffsetsStart]
+	pack.largeOffsets = idx[largeOffsetsStart:]
+	if pack.file, err = os.Open(packFile); err != nil {
+		return nil, err
+	}
+	return pack, nil
+}
+
+// Close closes the pack file
+func (pack *gitPack) Close() error {
+	return pack.file.Close()
+}
+
+// find returns the offset of the object with the given hash in the pack file, if the pack contains it
+func (pack *gitPack) find(h gitHash) (int64, bool) {
+	low := 0
+	if h[0] > 0 {
+		low = int(pack.fanout[h[0]-1])
+	}
+	high := int(pack.fanout[h[0]])
+	for low < high {
+		mid := (low + high) / 2
+		switch bytes.Compare(pack.hashes[20*mid:20*mid+20], h[:]) {
+		case 0:
+			offset := binary.BigEndian.Uint32(pack.offsets[4*mid:])
+			if offset&0x80000000 == 0 {
+				return int64(offset), true
+			}
+			i := int(offset & 0x7fffffff)
+			if 8*i+8 > len(pack.largeOffsets) {
+				return 0, false
+			}
+			return int64(binary.BigEndian.Uint64(pack.largeOffsets[8*i:])), true
+		case -1:
+			low = mid + 1
+		default:
+			high = mid
+		}
+	}
+	return 0, false
+}
+
+// readAt reads the object at the given offset in the pack file, resolving deltas against their base objects
+func (pack *gitPack) readAt(repo *gitRepository, offset int64) (int, []byte, error) {
+	key := gitCacheKey{pack: pack, offset: offset}
+	if obj, ok := repo.cached(key); ok {
+		return obj.kind, obj.data, nil
+	}
+	r := bufio.NewReader(io.NewSectionReader(pack.file, offset, 1<<62))
+
+	// The header is the type and the uncompressed size, as a variable length integer
+	c, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	kind := int(c>>4) & 7
+	size := uint64(c & 0x0f)
+	for shift := 4; c&0x80 != 0; shift += 7 {
+		if c, err = r.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+		size |= uint64(c&0x7f) << shift
+	}
+
+	var (
+		baseKind int
+		baseData []byte
+	)
+	switch kind {
+	case gitObjectCommit, gitObjectTree, gitObjectBlob, gitObjectTag:
+		data, err := inflate(r, size)
+		if err != nil {
+			return 0, nil, err
+		}
+		repo.remember(key, gitObject{kind: kind, data: data})
+		return kind, data, nil
+	case gitObjectOffsetDelta:
+		// The base object is at a negative offset from this object
+		if c, err = r.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+		distance := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			if c, err = r.ReadByte(); err != nil {
+				return 0, nil, err
+			}
+			distance = ((distance + 1) << 7) | int64(c&0x7f)
+		}
+		if baseKind, baseData, err = pack.readAt(repo, offset-distance); err != nil {
+			return 0, nil, err
+		}
+	case gitObjectRefDelta:
+		var base gitHash
+		if _, err := io.ReadFull(r, base[:]); err != nil {
+			return 0, nil, err
+		}
+		if baseKind, baseData, err = repo.readObject(base); err != nil {
+			return 0, nil, err
+		}
+	default:
+		return 0, nil, fmt.Errorf("unknown object type %d at offset %d", kind, offset)
+	}
+	delta, err := inflate(r, size)
+	if err != nil {
+		return 0, nil, err
+	}
+	data, err := applyDelta(baseData, delta)
+	if err != nil {
+		return 0, nil, fmt.Errorf("object at offset %d: %v", offset, err)
+	}
+	repo.remember(key, gitObject{kind: baseKind, data: data})
+	return baseKind, data, nil
+}
+
+// inflate decompresses size bytes of zlib compressed data
+func inflate(r io.Reader, size uint64) ([]byte, error) {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	data := make([]byte, size)
+	if _, err := io.ReadFull(zr, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// deltaSize reads a variable length size from the start of a delta
+func deltaSize(delta []byte) (uint64, []byte) {
+	var size uint64
+	for shift := 0; len(delta) > 0; shift += 7 {
+		c := delta[0]
+		delta = delta[1:]
+		size |= uint64(c&0x7f) << shift
+		if c&0x80 == 0 {
+			break
+		}
+	}
+	return size, delta
+}
+
+// applyDelta reconstructs an object from its base object and a delta with copy and insert instructions
+func applyDelta(base, delta []byte) ([]byte, error) {
+	baseSize, delta := deltaSize(delta)
+	if baseSize != uint64(len(base)) {
+		return nil, errors.New("delta base size mismatch")
+	}
+	targetSize, delta := deltaSize(delta)
+	result := make([]byte, 0, targetSize)
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+		switch {
+		case op&0x80 != 0:
+			// Copy from the base object. The low bits tell which offset and size bytes are present.
+			var offset, size uint64
+			for i := 0; i < 4; i++ {
+				if op&(1<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, errors.New("truncated delta")
+					}
+					offset |= uint64(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			for i := 0; i < 3; i++ {
+				if op&(0x10<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, errors.New("truncated delta")
+					}
+					size |= uint64(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > uint64(len(base)) {
+				return nil, errors.New("delta copies outside of the base object")
+			}
+			result = append(result, base[offset:offset+size]...)
+		case op != 0:
+			// Insert the next op bytes
+			if int(op) > len(delta) {
+				return nil, errors.New("truncated delta")
+			}
+			result = append(result, delta[:op]...)
+			delta = delta[op:]
+		default:
+			return nil, errors.New("invalid delta instruction")
+		}
+	}
+	if uint64(len(result)) != targetSize {
+		return nil, errors.New("delta target size mismatch")
+	}
+	return result, nil
+}
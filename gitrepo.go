@@ -0,0 +1,428 @@
+package projectinfo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Git object types, as used in pack files
+const (
+	gitObjectCommit = 1
+	gitObjectTree   = 2
+	gitObjectBlob   = 3
+	gitObjectTag    = 4
+)
+
+// errNotGitRepository is returned when no git repository can be found for a path
+var errNotGitRepository = errors.New("not a git repository")
+
+// gitHash is a SHA-1 object ID
+type gitHash [20]byte
+
+// String returns the hash as 40 hexadecimal digits
+func (h gitHash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// IsZero checks if the hash is all zeros, which is used for "no object"
+func (h gitHash) IsZero() bool {
+	return h == gitHash{}
+}
+
+// objectHash returns the hash of an object with the given type name and contents, like "git hash-object"
+func objectHash(kind string, data []byte) gitHash {
+	return sha1.Sum(append([]byte(fmt.Sprintf("%s %d\x00", kind, len(data))), data...))
+}
+
+// parseGitHash parses a hash that is written as 40 hexadecimal digits
+func parseGitHash(s string) (gitHash, error) {
+	var h gitHash
+	if len(s) != 40 {
+		return h, fmt.Errorf("invalid object ID: %q", s)
+	}
+	_, err := hex.Decode(h[:], []byte(s))
+	return h, err
+}
+
+// gitRepository gives read access to the objects and references of a git repository, without using the git command
+type gitRepository struct {
+	gitDir     string // the .git directory, or the worktree specific directory for linked worktrees
+	commonDir  string // the directory with the objects and most references, usually the same as gitDir
+	workTree   string // the directory with the checked out files
+	objectDirs []string
+	packs      []*gitPack
+	shallow    map[gitHash]bool // commits whose parents are not in a shallow clone
+
+	mut        sync.Mutex
+	cache      map[gitCacheKey]gitObject // recently read objects, mainly delta bases
+	cacheBytes int
+}
+
+// gitObject is the type and uncompressed contents of an object
+type gitObject struct {
+	kind int
+	data []byte
+}
+
+// gitCacheKey identifies an object in the cache, either by hash or by its offset in a pack file
+type gitCacheKey struct {
+	hash   gitHash
+	pack   *gitPack
+	offset int64
+}
+
+// maxGitCacheBytes is roughly how much object data a gitRepository keeps in memory
+const maxGitCacheBytes = 64 * 1024 * 1024
+
+// findGitDir searches the given path and its parent directories for a git repository,
+// and returns the git directory and the work tree. A .git file with a "gitdir:" line, as used by worktrees and submodules, is followed.
+func findGitDir(path string) (gitDir, workTree string, err error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", err
+	}
+	if !isDir(dir) {
+		dir = filepath.Dir(dir)
+	}
+	for {
+		candidate := filepath.Join(dir, ".git")
+		if fi, err := os.Stat(candidate); err == nil {
+			if fi.IsDir() {
+				return candidate, dir, nil
+			}
+			if gitDir, err := readGitDirFile(candidate); err == nil {
+				return gitDir, dir, nil
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", errNotGitRepository
+		}
+		dir = parent
+	}
+}
+
+// readGitDirFile reads a .git file that contains a "gitdir: <path>" line, and returns the absolute path it points to
+func readGitDirFile(filename string) (string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	line := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(line, "gitdir:") {
+		return "", fmt.Errorf("%s does not contain a gitdir line", filename)
+	}
+	gitDir := strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(filename), gitDir)
+	}
+	return filepath.Clean(gitDir), nil
+}
+
+// openGitRepository opens the git repository that contains the given path
+func openGitRepository(path string) (*gitRepository, error) {
+	gitDir, workTree, err := findGitDir(path)
+	if err != nil {
+		return nil, err
+	}
+	repo := &gitRepository{
+		gitDir:   gitDir,
+		workTree: workTree,
+		shallow:  make(map[gitHash]bool),
+		cache:    make(map[gitCacheKey]gitObject),
+	}
+	// Linked worktrees share the objects and references of the main repository
+	repo.commonDir = readCommonDir(gitDir)
+	objectsDir := filepath.Join(repo.commonDir, "objects")
+	if !isDir(objectsDir) {
+		return nil, fmt.Errorf("%s: %w", gitDir, errNotGitRepository)
+	}
+	repo.objectDirs = append([]string{objectsDir}, readAlternates(objectsDir)...)
+	for _, objectDir := range repo.objectDirs {
+		packs, err := openGitPacks(filepath.Join(objectDir, "pack"))
+		if err != nil {
+			repo.Close()
+			return nil, err
+		}
+		repo.packs = append(repo.packs, packs...)
+	}
+	if data, err := os.ReadFile(filepath.Join(repo.commonDir, "shallow")); err == nil {
+		for _, line := range strings.Fields(string(data)) {
+			if h, err := parseGitHash(line); err == nil {
+				repo.shallow[h] = true
+			}
+		}
+	}
+	return repo, nil
+}
+
+// readAlternates returns the additional object directories listed in objects/info/alternates
+func readAlternates(objectsDir string) []string {
+	data, err := os.ReadFile(filepath.Join(objectsDir, "info", "alternates"))
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(objectsDir, line)
+		}
+		dirs = append(dirs, filepath.Clean(line))
+	}
+	return dirs
+}
+
+// Close closes the pack files of the repository
+func (repo *gitRepository) Close() error {
+	var errs []error
+	for _, pack := range repo.packs {
+		if err := pack.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// cached returns an object from the cache, if it is there
+func (repo *gitRepository) cached(key gitCacheKey) (gitObject, bool) {
+	repo.mut.Lock()
+	defer repo.mut.Unlock()
+	obj, ok := repo.cache[key]
+	return obj, ok
+}
+
+// remember adds an object to the cache, clearing the cache first if it is full
+func (repo *gitRepository) remember(key gitCacheKey, obj gitObject) {
+	repo.mut.Lock()
+	defer repo.mut.Unlock()
+	if repo.cacheBytes+len(obj.data) > maxGitCacheBytes {
+		repo.cache = make(map[gitCacheKey]gitObject)
+		repo.cacheBytes = 0
+	}
+	repo.cache[key] = obj
+	repo.cacheBytes += len(obj.data)
+}
+
+// readObject returns the type and contents of the object with the given hash, from either a loose object file or a pack file
+func (repo *gitRepository) readObject(h gitHash) (int, []byte, error) {
+	if obj, ok := repo.cached(gitCacheKey{hash: h}); ok {
+		return obj.kind, obj.data, nil
+	}
+	for _, pack := range repo.packs {
+		if offset, ok := pack.find(h); ok {
+			return pack.readAt(repo, offset)
+		}
+	}
+	hexHash := h.String()
+	for _, objectDir := range repo.objectDirs {
+		kind, data, err := readLooseObject(filepath.Join(objectDir, hexHash[:2], hexHash[2:]))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return 0, nil, fmt.Errorf("object %s: %v", hexHash, err)
+		}
+		repo.remember(gitCacheKey{hash: h}, gitObject{kind: kind, data: data})
+		return kind, data, nil
+	}
+	return 0, nil, fmt.Errorf("object %s: %w", hexHash, fs.ErrNotExist)
+}
+
+// readLooseObject reads and decompresses a loose object file
+func readLooseObject(filename string) (int, []byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+	zr, err := zlib.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return 0, nil, err
+	}
+	nul := bytes.IndexByte(data, 0)
+	if nul < 0 {
+		return 0, nil, errors.New("missing object header")
+	}
+	kindName, sizeString, found := strings.Cut(string(data[:nul]), " ")
+	if !found {
+		return 0, nil, errors.New("invalid object header")
+	}
+	size, err := strconv.Atoi(sizeString)
+	if err != nil || size != len(data)-nul-1 {
+		return 0, nil, errors.New("invalid object size")
+	}
+	var kind int
+	switch kindName {
+	case "commit":
+		kind = gitObjectCommit
+	case "tree":
+		kind = gitObjectTree
+	case "blob":
+		kind = gitObjectBlob
+	case "tag":
+		kind = gitObjectTag
+	default:
+		return 0, nil, fmt.Errorf("unknown object type %q", kindName)
+	}
+	return kind, data[nul+1:], nil
+}
+
+// readRef returns the hash a reference points to, following symbolic references like HEAD
+func (repo *gitRepository) readRef(name string) (gitHash, error) {
+	for depth := 0; depth < 10; depth++ {
+		value, err := repo.readRefValue(name)
+		if err != nil {
+			return gitHash{}, err
+		}
+		if target, ok := strings.CutPrefix(value, "ref:"); ok {
+			name = strings.TrimSpace(target)
+			continue
+		}
+		return parseGitHash(value)
+	}
+	return gitHash{}, fmt.Errorf("too many levels of symbolic references for %s", name)
+}
+
+// readRefValue returns the contents of a loose or packed reference, which is either a hash or "ref: <name>"
+func (repo *gitRepository) readRefValue(name string) (string, error) {
+	// HEAD and other per-worktree references are in gitDir, the rest in commonDir
+	for _, dir := range []string{repo.gitDir, repo.commonDir} {
+		if data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name))); err == nil {
+			return strings.TrimSpace(string(data)), nil
+		}
+	}
+	packed, err := repo.packedRefs()
+	if err != nil {
+		return "", err
+	}
+	if h, ok := packed[name]; ok {
+		return h.String(), nil
+	}
+	return "", fmt.Errorf("reference %s: %w", name, fs.ErrNotExist)
+}
+
+// packedRefs reads the packed-refs file of the repository
+func (repo *gitRepository) packedRefs() (map[string]gitHash, error) {
+	refs := make(map[string]gitHash)
+	data, err := os.ReadFile(filepath.Join(repo.commonDir, "packed-refs"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return refs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+			continue // comments and peeled tags
+		}
+		hexHash, name, found := strings.Cut(strings.TrimSpace(line), " ")
+		if !found {
+			continue
+		}
+		if h, err := parseGitHash(hexHash); err == nil {
+			refs[name] = h
+		}
+	}
+	return refs, nil
+}
+
+// refNames returns the names of all references under refs/, both loose and packed
+func (repo *gitRepository) refNames() ([]string, error) {
+	packed, err := repo.packedRefs()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for name := range packed {
+		seen[name] = true
+		names = append(names, name)
+	}
+	refsDir := filepath.Join(repo.commonDir, "refs")
+	err = filepath.WalkDir(refsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(repo.commonDir, path)
+		if err != nil {
+			return nil
+		}
+		name := filepath.ToSlash(rel)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+		return nil
+	})
+	return names, err
+}
+
+// tips returns the commits that HEAD and all references point to, like "git log --all" starts from.
+// Annotated tags are peeled, and references to anything other than commits are left out.
+func (repo *gitRepository) tips() ([]gitHash, error) {
+	names, err := repo.refNames()
+	if err != nil {
+		return nil, err
+	}
+	names = append(names, "HEAD")
+	var (
+		tips []gitHash
+		seen = make(map[gitHash]bool)
+	)
+	for _, name := range names {
+		h, err := repo.readRef(name)
+		if err != nil {
+			continue // for instance HEAD in a repository without commits
+		}
+		h, err = repo.peel(h)
+		if err != nil || seen[h] {
+			continue
+		}
+		seen[h] = true
+		tips = append(tips, h)
+	}
+	return tips, nil
+}
+
+// peel follows annotated tags until it reaches a commit
+func (repo *gitRepository) peel(h gitHash) (gitHash, error) {
+	for depth := 0; depth < 10; depth++ {
+		kind, data, err := repo.readObject(h)
+		if err != nil {
+			return gitHash{}, err
+		}
+		switch kind {
+		case gitObjectCommit:
+			return h, nil
+		case gitObjectTag:
+			target, _, _ := strings.Cut(strings.TrimPrefix(string(data), "object "), "\n")
+			if h, err = parseGitHash(target); err != nil {
+				return gitHash{}, err
+			}
+		default:
+			return gitHash{}, fmt.Errorf("object %s is not a commit", h)
+		}
+	}
+	return gitHash{}, fmt.Errorf("too many levels of tags for %s", h)
+}
@@ -0,0 +1,547 @@
+package projectinfo
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testGitRepo builds a git repository in a temporary directory by writing objects directly, without the git command
+type testGitRepo struct {
+	t   *testing.T
+	dir string
+}
+
+func newTestGitRepo(t *testing.T) *testGitRepo {
+	t.Helper()
+	dir := t.TempDir()
+	for _, sub := range []string{"objects", "refs/heads", "refs/tags"} {
+		if err := os.MkdirAll(filepath.Join(dir, ".git", sub), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+	repo := &testGitRepo{t: t, dir: dir}
+	repo.writeFile(".git/HEAD", "ref: refs/heads/main\n")
+	return repo
+}
+
+func (repo *testGitRepo) writeFile(name, content string) {
+	repo.t.Helper()
+	path := filepath.Join(repo.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		repo.t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		repo.t.Fatalf("Failed to write %s: %v", name, err)
+	}
+}
+
+func (repo *testGitRepo) writeObject(kind string, data []byte) gitHash {
+	repo.t.Helper()
+	full := append([]byte(fmt.Sprintf("%s %d\x00", kind, len(data))), data...)
+	h := objectHash(kind, data)
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(full)
+	zw.Close()
+	hexHash := h.String()
+	repo.writeFile(".git/objects/"+hexHash[:2]+"/"+hexHash[2:], buf.String())
+	return h
+}
+
+func (repo *testGitRepo) blob(content string) gitHash {
+	return repo.writeObject("blob", []byte(content))
+}
+
+// tree writes a tree object, where names ending with "/" are subtrees and names ending with "@" are submodules
+func (repo *testGitRepo) tree(entries map[string]gitHash) gitHash {
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf bytes.Buffer
+	for _, name := range names {
+		mode := "100644"
+		switch {
+		case strings.HasSuffix(name, "/"):
+			mode = "40000"
+		case strings.HasSuffix(name, "@"):
+			mode = gitSubmoduleMode
+		}
+		h := entries[name]
+		fmt.Fprintf(&buf, "%s %s\x00", mode, strings.TrimRight(name, "/@"))
+		buf.Write(h[:])
+	}
+	return repo.writeObject("tree", buf.Bytes())
+}
+
+func (repo *testGitRepo) commit(tree gitHash, author string, when int64, parents ...gitHash) gitHash {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "tree %s\n", tree)
+	for _, parent := range parents {
+		fmt.Fprintf(&buf, "parent %s\n", parent)
+	}
+	email := strings.ToLower(strings.Fields(author)[0]) + "@example.com"
+	fmt.Fprintf(&buf, "author %s <%s> %d +0000\n", author, email, when)
+	fmt.Fprintf(&buf, "committer %s <%s> %d +0000\n", author, email, when)
+	fmt.Fprintf(&buf, "\nCommit by %s\n", author)
+	return repo.writeObject("commit", buf.Bytes())
+}
+
+func (repo *testGitRepo) setRef(name string, h gitHash) {
+	repo.writeFile(".git/"+name, h.String()+"\n")
+}
+
+// buildTestHistory creates a small history with a side branch and a merge commit
+func buildTestHistory(t *testing.T) *testGitRepo {
+	repo := newTestGitRepo(t)
+	a1, a2 := repo.blob("package a\n"), repo.blob("package a\n\nfunc A() {}\n")
+	b1, b2 := repo.blob("package b\n"), repo.blob("package b\n\nfunc B() {}\n")
+	c1 := repo.blob("package c\n")
+
+	c1Commit := repo.commit(repo.tree(map[string]gitHash{"a.go": a1, "dir/": repo.tree(map[string]gitHash{"b.go": b1})}), "Alice", 1000)
+	c2Commit := repo.commit(repo.tree(map[string]gitHash{"a.go": a2, "dir/": repo.tree(map[string]gitHash{"b.go": b1})}), "Bob", 2000, c1Commit)
+	c3Commit := repo.commit(repo.tree(map[string]gitHash{"a.go": a2, "dir/": repo.tree(map[string]gitHash{"b.go": b2})}), "Alice", 3000, c2Commit)
+	side := repo.commit(repo.tree(map[string]gitHash{"a.go": a1, "c.go": c1, "dir/": repo.tree(map[string]gitHash{"b.go": b1})}), "Carol", 2500, c1Commit)
+	merge := repo.commit(repo.tree(map[string]gitHash{"a.go": a2, "c.go": c1, "dir/": repo.tree(map[string]gitHash{"b.go": b2})}), "Dave", 4000, c3Commit, side)
+	repo.setRef("refs/heads/main", merge)
+	repo.setRef("refs/heads/side", side)
+	return repo
+}
+
+func TestGitContributors(t *testing.T) {
+	repo := buildTestHistory(t)
+
+	got, err := GitContributors(repo.dir)
+	if err != nil {
+		t.Fatalf("GitContributors() error = %v", err)
+	}
+	if want := []string{"Alice", "Bob", "Carol"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GitContributors() = %v, want %v", got, want)
+	}
+
+	testCases := []struct {
+		path string
+		want []string
+	}{
+		{"a.go", []string{"Alice", "Bob"}},
+		{"dir", []string{"Alice"}},
+		{"dir/b.go", []string{"Alice"}},
+		{"c.go", []string{"Carol"}},
+		{"missing.go", []string{}},
+	}
+	histories := newGitHistories()
+	defer histories.Close()
+	for _, tc := range testCases {
+		got := contributorsForFile(histories, filepath.Join(repo.dir, filepath.FromSlash(tc.path)))
+		if len(got) == 0 && len(tc.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("contributors for %s = %v, want %v", tc.path, got, tc.want)
+		}
+	}
+
+	if _, err := GitContributors(t.TempDir()); err == nil {
+		t.Error("GitContributors() outside of a repository should fail")
+	}
+}
+
+func TestGitContributorDetails(t *testing.T) {
+	repo := buildTestHistory(t)
+
+	got, err := GitContributorDetails(repo.dir)
+	if err != nil {
+		t.Fatalf("GitContributorDetails() error = %v", err)
+	}
+	when := func(seconds int64) time.Time {
+		return time.Unix(seconds, 0)
+	}
+	want := []Contributor{
+		{Name: "Alice", Emails: []string{"alice@example.com"}, Commits: 2, FirstCommit: when(1000), LastCommit: when(3000), LinesAdded: 4},
+		{Name: "Bob", Emails: []string{"bob@example.com"}, Commits: 1, FirstCommit: when(2000), LastCommit: when(2000), LinesAdded: 2},
+		{Name: "Carol", Emails: []string{"carol@example.com"}, Commits: 1, FirstCommit: when(2500), LastCommit: when(2500), LinesAdded: 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("GitContributorDetails() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].Name != want[i].Name || !reflect.DeepEqual(got[i].Emails, want[i].Emails) || got[i].Commits != want[i].Commits ||
+			!got[i].FirstCommit.Equal(want[i].FirstCommit) || !got[i].LastCommit.Equal(want[i].LastCommit) ||
+			got[i].LinesAdded != want[i].LinesAdded || got[i].LinesRemoved != want[i].LinesRemoved {
+			t.Errorf("GitContributorDetails()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseContributorLine(t *testing.T) {
+	testCases := []struct {
+		line string
+		want Contributor
+		ok   bool
+	}{
+		{"    42\tJane Doe <jane@example.com>", Contributor{Name: "Jane Doe", Emails: []string{"jane@example.com"}, Commits: 42}, true},
+		{"     3\tJohn", Contributor{Name: "John", Commits: 3}, true},
+		{"John", Contributor{}, false},
+		{"x\tJohn", Contributor{}, false},
+	}
+	for _, tc := range testCases {
+		got, ok := ParseContributorLine(tc.line)
+		if ok != tc.ok || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ParseContributorLine(%q) = %+v, %v, want %+v, %v", tc.line, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestGitOwnership(t *testing.T) {
+	repo := buildTestHistory(t)
+
+	testCases := []struct {
+		path string
+		want []LineOwnership
+	}{
+		{"a.go", []LineOwnership{
+			{Author: "Bob", Email: "bob@example.com", Lines: 2, Percentage: 66.7, LastCommitDate: "1970-01-01T00:33:20Z"},
+			{Author: "Alice", Email: "alice@example.com", Lines: 1, Percentage: 33.3, LastCommitDate: "1970-01-01T00:16:40Z"},
+		}},
+		{"c.go", []LineOwnership{
+			{Author: "Carol", Email: "carol@example.com", Lines: 1, Percentage: 100, LastCommitDate: "1970-01-01T00:41:40Z"},
+		}},
+		{"missing.go", nil},
+	}
+	for _, tc := range testCases {
+		got, err := GitOwnership(filepath.Join(repo.dir, tc.path))
+		if err != nil {
+			t.Fatalf("GitOwnership(%s) error = %v", tc.path, err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("GitOwnership(%s) = %+v, want %+v", tc.path, got, tc.want)
+		}
+	}
+
+	// Ownership is only filled in when asked for
+	repo.writeFile("a.go", "package a\n\nfunc A() {}\n")
+	for _, blame := range []bool{false, true} {
+		project, err := NewWithOptions(repo.dir, Options{Blame: blame, NoAPIServerCheck: true})
+		if err != nil {
+			t.Fatalf("NewWithOptions() error = %v", err)
+		}
+		ownership := FindFileName(project.SourceFiles, "a.go").Ownership
+		if (ownership != nil) != blame || (blame && !reflect.DeepEqual(ownership, testCases[0].want)) {
+			t.Errorf("ownership of a.go with Blame %v = %+v", blame, ownership)
+		}
+	}
+}
+
+func TestMatchLines(t *testing.T) {
+	testCases := []struct {
+		a, b string
+		want [][2]int
+	}{
+		{"a b c", "a b c", [][2]int{{0, 0}, {1, 1}, {2, 2}}},
+		{"a b c", "a x c", [][2]int{{0, 0}, {2, 2}}},
+		{"a b c d", "b c x d", [][2]int{{1, 0}, {2, 1}, {3, 3}}},
+		{"", "a", nil},
+		{"a b", "", nil},
+	}
+	for _, tc := range testCases {
+		got := matchLines(strings.Fields(tc.a), strings.Fields(tc.b))
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("matchLines(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+// testPackObject is an object to be written to a test pack file, either in full or as a delta against an earlier object
+type testPackObject struct {
+	kind    int
+	data    []byte // the full contents of the object
+	ofsBase int    // index of the base object for an offset delta, or -1
+	refBase bool   // use a reference delta against the previous object instead
+}
+
+// makeDelta creates a delta that copies the common prefix of base and target, and inserts the rest of target
+func makeDelta(base, target []byte) []byte {
+	varint := func(n int) []byte {
+		var b []byte
+		for {
+			c := byte(n & 0x7f)
+			n >>= 7
+			if n > 0 {
+				b = append(b, c|0x80)
+				continue
+			}
+			return append(b, c)
+		}
+	}
+	delta := append(varint(len(base)), varint(len(target))...)
+	common := 0
+	for common < len(base) && common < len(target) && common < 0xffff && base[common] == target[common] {
+		common++
+	}
+	if common > 0 {
+		// Copy from offset 0, with a two byte size
+		delta = append(delta, 0x80|0x10|0x20, byte(common), byte(common>>8))
+	}
+	for rest := target[common:]; len(rest) > 0; {
+		n := len(rest)
+		if n > 127 {
+			n = 127
+		}
+		delta = append(delta, byte(n))
+		delta = append(delta, rest[:n]...)
+		rest = rest[n:]
+	}
+	return delta
+}
+
+// writeTestPack writes a pack file and a version 2 index with the given objects, and returns the hashes of the objects
+func writeTestPack(t *testing.T, packDir string, objects []testPackObject) []gitHash {
+	t.Helper()
+	var pack bytes.Buffer
+	pack.WriteString("PACK")
+	binary.Write(&pack, binary.BigEndian, uint32(2))
+	binary.Write(&pack, binary.BigEndian, uint32(len(objects)))
+
+	hashes := make([]gitHash, len(objects))
+	offsets := make([]int64, len(objects))
+	kindNames := map[int]string{gitObjectCommit: "commit", gitObjectTree: "tree", gitObjectBlob: "blob", gitObjectTag: "tag"}
+	for i, obj := range objects {
+		hashes[i] = objectHash(kindNames[obj.kind], obj.data)
+		offsets[i] = int64(pack.Len())
+		kind, payload := obj.kind, obj.data
+		switch {
+		case obj.ofsBase >= 0:
+			kind, payload = gitObjectOffsetDelta, makeDelta(objects[obj.ofsBase].data, obj.data)
+		case obj.refBase:
+			kind, payload = gitObjectRefDelta, makeDelta(objects[i-1].data, obj.data)
+		}
+		// Type and size header
+		size := len(payload)
+		c := byte(kind<<4) | byte(size&0x0f)
+		size >>= 4
+		for size > 0 {
+			pack.WriteByte(c | 0x80)
+			c = byte(size & 0x7f)
+			size >>= 7
+		}
+		pack.WriteByte(c)
+		switch {
+		case obj.ofsBase >= 0:
+			distance := offsets[i] - offsets[obj.ofsBase]
+			encoded := []byte{byte(distance & 0x7f)}
+			for distance >>= 7; distance > 0; distance >>= 7 {
+				distance--
+				encoded = append([]byte{byte(0x80 | distance&0x7f)}, encoded...)
+			}
+			pack.Write(encoded)
+		case obj.refBase:
+			pack.Write(hashes[i-1][:])
+		}
+		zw := zlib.NewWriter(&pack)
+		zw.Write(payload)
+		zw.Close()
+	}
+	packSum := sha1.Sum(pack.Bytes())
+	pack.Write(packSum[:])
+
+	order := make([]int, len(objects))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return bytes.Compare(hashes[order[a]][:], hashes[order[b]][:]) < 0 })
+	var idx bytes.Buffer
+	idx.WriteString("\xfftOc")
+	binary.Write(&idx, binary.BigEndian, uint32(2))
+	for b := 0; b < 256; b++ {
+		count := 0
+		for _, i := range order {
+			if int(hashes[i][0]) <= b {
+				count++
+			}
+		}
+		binary.Write(&idx, binary.BigEndian, uint32(count))
+	}
+	for _, i := range order {
+		idx.Write(hashes[i][:])
+	}
+	for range order {
+		binary.Write(&idx, binary.BigEndian, uint32(0)) // CRC32 values are not checked
+	}
+	for _, i := range order {
+		binary.Write(&idx, binary.BigEndian, uint32(offsets[i]))
+	}
+	idx.Write(packSum[:])
+	idxSum := sha1.Sum(idx.Bytes())
+	idx.Write(idxSum[:])
+
+	name := "pack-" + gitHash(packSum).String()
+	if err := os.WriteFile(filepath.Join(packDir, name+".pack"), pack.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write pack: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(packDir, name+".idx"), idx.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write pack index: %v", err)
+	}
+	return hashes
+}
+
+func TestGitPackDeltas(t *testing.T) {
+	repo := newTestGitRepo(t)
+	packDir := filepath.Join(repo.dir, ".git", "objects", "pack")
+	if err := os.MkdirAll(packDir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	base := []byte(strings.Repeat("package main\n", 20))
+	second := append(append([]byte{}, base...), "func main() {}\n"...)
+	third := append(append([]byte{}, second...), "// the end\n"...)
+	hashes := writeTestPack(t, packDir, []testPackObject{
+		{kind: gitObjectBlob, data: base, ofsBase: -1},
+		{kind: gitObjectBlob, data: second, ofsBase: 0},
+		{kind: gitObjectBlob, data: third, ofsBase: -1, refBase: true},
+	})
+
+	gitRepo, err := openGitRepository(repo.dir)
+	if err != nil {
+		t.Fatalf("openGitRepository() error = %v", err)
+	}
+	defer gitRepo.Close()
+	for i, want := range [][]byte{base, second, third} {
+		kind, data, err := gitRepo.readObject(hashes[i])
+		if err != nil {
+			t.Fatalf("readObject(%d) error = %v", i, err)
+		}
+		if kind != gitObjectBlob || !bytes.Equal(data, want) {
+			t.Errorf("readObject(%d) = %d %q, want a blob with %q", i, kind, data, want)
+		}
+	}
+}
+
+// TestGitContributorsMatchesGit compares the results with the git command, for a repository that has been packed with git gc
+func TestGitContributorsMatchesGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	git := func(author string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME="+author, "GIT_AUTHOR_EMAIL="+strings.ToLower(author)+"@example.com",
+			"GIT_COMMITTER_NAME="+author, "GIT_COMMITTER_EMAIL="+strings.ToLower(author)+"@example.com",
+			"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+		return string(output)
+	}
+	write := func(name, content string) {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	git("", "init", "-q", "-b", "main")
+	authors := []string{"Alice", "Bob", "Carol", "Alice", "Dave", "Bob", "Alice"}
+	var contents strings.Builder
+	for i, author := range authors {
+		fmt.Fprintf(&contents, "line %d by %s\n", i, author)
+		write("main.go", contents.String())
+		write(fmt.Sprintf("pkg/file%d.go", i%3), fmt.Sprintf("package pkg // %d\n", i))
+		git(author, "add", "-A")
+		git(author, "commit", "-q", "-m", fmt.Sprintf("commit %d", i))
+	}
+	git("Erin", "checkout", "-q", "-b", "feature", "HEAD~3")
+	write("feature.go", "package main\n")
+	git("Erin", "add", "-A")
+	git("Erin", "commit", "-q", "-m", "feature")
+	git("Erin", "checkout", "-q", "main")
+	git("Frank", "merge", "-q", "--no-ff", "-m", "merge", "feature")
+	git("Frank", "tag", "-a", "-m", "release", "v1.0")
+	git("", "gc", "-q", "--aggressive")
+	write(".mailmap", "Bob <bob@example.com> <dave@example.com>\n")
+
+	shortlog := func(args ...string) []string {
+		var names []string
+		output := git("", append([]string{"shortlog", "-sn", "--all", "--no-merges", "HEAD"}, args...)...)
+		for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+			if name := ParseContributor(line); name != "" {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+
+	got, err := GitContributors(dir)
+	if err != nil {
+		t.Fatalf("GitContributors() error = %v", err)
+	}
+	if want := shortlog(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GitContributors() = %v, git shortlog gives %v", got, want)
+	}
+	histories := newGitHistories()
+	defer histories.Close()
+	for _, path := range []string{"main.go", "pkg/file1.go", "pkg", "feature.go"} {
+		got := contributorsForFile(histories, filepath.Join(dir, path))
+		if want := shortlog("--", path); !reflect.DeepEqual(got, want) {
+			t.Errorf("contributors for %s = %v, git shortlog gives %v", path, got, want)
+		}
+	}
+
+	numstat := make(map[string][2]int)
+	var author string
+	for _, line := range strings.Split(git("", "log", "--all", "--no-merges", "--numstat", "--format=@%aN"), "\n") {
+		if name, ok := strings.CutPrefix(line, "@"); ok {
+			author = name
+			continue
+		}
+		var added, removed int
+		if _, err := fmt.Sscanf(line, "%d\t%d", &added, &removed); err == nil {
+			counts := numstat[author]
+			numstat[author] = [2]int{counts[0] + added, counts[1] + removed}
+		}
+	}
+	details, err := GitContributorDetails(dir)
+	if err != nil {
+		t.Fatalf("GitContributorDetails() error = %v", err)
+	}
+	for _, contributor := range details {
+		if got := [2]int{contributor.LinesAdded, contributor.LinesRemoved}; got != numstat[contributor.Name] {
+			t.Errorf("lines added and removed by %s = %v, git log --numstat gives %v", contributor.Name, got, numstat[contributor.Name])
+		}
+	}
+
+	for _, path := range []string{"main.go", "pkg/file1.go", "feature.go"} {
+		want := make(map[string]int)
+		for _, line := range strings.Split(git("", "blame", "--line-porcelain", "HEAD", "--", path), "\n") {
+			if author, ok := strings.CutPrefix(line, "author "); ok {
+				want[author]++
+			}
+		}
+		ownership, err := GitOwnership(filepath.Join(dir, path))
+		if err != nil {
+			t.Fatalf("GitOwnership(%s) error = %v", path, err)
+		}
+		got := make(map[string]int)
+		for _, owner := range ownership {
+			got[owner.Author] = owner.Lines
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ownership of %s = %v, git blame gives %v", path, got, want)
+		}
+	}
+}
@@ -2,44 +2,463 @@ package projectinfo
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 )
 
-// LoadIgnorePatterns loads patterns from specified filenames to ignore during file operations
-func LoadIgnorePatterns(filenames ...string) (map[string]struct{}, error) {
-	ignores := make(map[string]struct{})
+// Precedence levels for ignore patterns. When several patterns match a path, the one with the highest level wins,
+// and within the same level, the last pattern wins. This mirrors the rules of git.
+const (
+	levelBuiltin       = iota // built-in patterns, like DefaultIgnores
+	levelGlobalExclude        // the core.excludesFile of the user
+	levelInfoExclude          // .git/info/exclude
+	levelIgnoreFile           // .gitignore files, where deeper directories get higher levels
+)
+
+// levelExclude is the precedence level of patterns given with Options.Exclude, which take precedence over all ignore files
+const levelExclude = 1 << 30
+
+// IgnorePattern is a single parsed pattern from a .gitignore file or similar
+type IgnorePattern struct {
+	Pattern  string // the pattern as written, including any leading "!" or trailing "/"
+	Source   string // the file the pattern was read from, or "" for built-in patterns
+	base     string // absolute, slash separated directory the pattern is relative to, with a trailing slash, or "" if it applies anywhere
+	level    int
+	negate   bool
+	dirOnly  bool
+	basename bool // true if the pattern has no slash, and should be matched against the name of the file or directory only
+	re       *regexp.Regexp
+}
+
+// Ignorer decides which files and directories to skip, following the same rules as .gitignore files in git
+type Ignorer struct {
+	root     string // absolute path of the directory tree that is being scanned
+	cwd      string // working directory, for resolving relative paths
+	patterns []IgnorePattern
+	loaded   map[string]bool // ignore files that have already been read
+	files    []IgnoreFile
+}
+
+// IgnoreFile is an ignore file that has been read by an Ignorer, and the number of patterns it contributed
+type IgnoreFile struct {
+	Path     string `json:"path"`
+	Patterns int    `json:"patterns"`
+}
+
+// NewIgnorer creates an Ignorer without any patterns, for the directory tree at the given root
+func NewIgnorer(root string) *Ignorer {
+	cwd, _ := os.Getwd()
+	ig := &Ignorer{cwd: cwd, loaded: make(map[string]bool)}
+	ig.root = ig.absSlash(root)
+	return ig
+}
+
+// absSlash returns the absolute, cleaned and slash separated version of the given path
+func (ig *Ignorer) absSlash(p string) string {
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(ig.cwd, p)
+	}
+	return filepath.ToSlash(filepath.Clean(p))
+}
+
+// withTrailingSlash adds a trailing slash to a slash separated directory path, if it does not already have one
+func withTrailingSlash(dir string) string {
+	if strings.HasSuffix(dir, "/") {
+		return dir
+	}
+	return dir + "/"
+}
+
+// DefaultIgnores are the common ignores typically found in projects, which are added by LoadIgnorePatterns.
+// The list can be replaced, or adjusted per call with IgnoreOptions. Version control directories are always skipped, see vcsDirs.
+var DefaultIgnores = []string{"vendor", "tmp", "backup", "node_modules", "target", ".mvn", ".gradle"}
+
+// vcsDirs are the metadata directories of version control systems, which are never collected, regardless of the ignore options
+var vcsDirs = map[string]bool{".git": true, ".hg": true, ".svn": true}
+
+// IgnorePresets are sets of ignore patterns for the build output and dependency directories of common ecosystems
+var IgnorePresets = map[string][]string{
+	"go":         {"vendor/"},
+	"java":       {"target/", "build/", ".gradle/", ".mvn/", "out/", "*.class"},
+	"javascript": {"node_modules/", "dist/", ".next/", ".nuxt/", ".svelte-kit/", "coverage/", ".cache/", "*.min.js"},
+	"python":     {"venv/", ".venv/", "env/", "__pycache__/", "*.pyc", ".tox/", ".mypy_cache/", ".pytest_cache/", ".ruff_cache/", "*.egg-info/", "build/", "dist/"},
+	"rust":       {"target/"},
+}
+
+// IgnoreOptions configures which built-in patterns are used in addition to the patterns in ignore files
+type IgnoreOptions struct {
+	NoDefaults bool     // do not use DefaultIgnores
+	Presets    []string // names of presets from IgnorePresets to include, like "python"
+	Add        []string // extra patterns
+	Remove     []string // patterns to leave out, from the defaults, the presets or Add
+}
+
+// Patterns returns the built-in patterns that the options result in
+func (opts IgnoreOptions) Patterns() ([]string, error) {
+	var patterns []string
+	if !opts.NoDefaults {
+		patterns = append(patterns, DefaultIgnores...)
+	}
+	for _, name := range opts.Presets {
+		preset, ok := IgnorePresets[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown ignore preset: %q", name)
+		}
+		patterns = append(patterns, preset...)
+	}
+	patterns = append(patterns, opts.Add...)
+	var (
+		result []string
+		seen   = make(map[string]bool)
+	)
+	for _, pattern := range patterns {
+		if seen[pattern] || slices.Contains(opts.Remove, pattern) {
+			continue
+		}
+		seen[pattern] = true
+		result = append(result, pattern)
+	}
+	return result, nil
+}
+
+// LoadIgnorePatterns loads patterns from specified filenames to ignore during file operations, together with DefaultIgnores
+func LoadIgnorePatterns(filenames ...string) (*Ignorer, error) {
+	return LoadIgnorePatternsWithOptions(IgnoreOptions{}, filenames...)
+}
+
+// LoadIgnorePatternsWithOptions loads patterns from specified filenames, together with the built-in patterns given by the options
+func LoadIgnorePatternsWithOptions(opts IgnoreOptions, filenames ...string) (*Ignorer, error) {
+	ignores := NewIgnorer(".")
 	for _, filename := range filenames {
-		data, err := os.ReadFile(filename)
-		if err != nil {
+		if err := ignores.AddFile(filename); err != nil {
 			continue // skip files that cannot be read
 		}
-		scanner := bufio.NewScanner(strings.NewReader(string(data)))
-		for scanner.Scan() {
-			line := strings.TrimSpace(scanner.Text())
-			if line != "" && !strings.HasPrefix(line, "#") {
-				ignores[line] = struct{}{}
+	}
+	builtins, err := opts.Patterns()
+	if err != nil {
+		return nil, err
+	}
+	ignores.AddBuiltins(builtins...)
+	return ignores, nil
+}
+
+// ignoreFilenames are the names of the ignore files that are read from each directory of a project
+var ignoreFilenames = []string{".gitignore", ".ignore"}
+
+// LoadProjectIgnores creates an Ignorer for the project in the given directory. It reads the global git excludes file,
+// .git/info/exclude and the .gitignore and .ignore files in dir, and adds the built-in patterns given by the options.
+// The ignore files in subdirectories are read by CollectFiles, as they are found.
+func LoadProjectIgnores(dir string, opts IgnoreOptions) (*Ignorer, error) {
+	builtins, err := opts.Patterns()
+	if err != nil {
+		return nil, err
+	}
+	ignores := NewIgnorer(dir)
+	ignores.AddBuiltins(builtins...)
+	ignores.AddGitExcludes(dir)
+	if err := ignores.AddDir(dir); err != nil {
+		return ignores, err
+	}
+	return ignores, nil
+}
+
+// AddDir reads the .gitignore and .ignore files in the given directory, if they exist
+func (ig *Ignorer) AddDir(dir string) error {
+	var errs []error
+	for _, name := range ignoreFilenames {
+		if err := ig.AddFile(filepath.Join(dir, name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// AddBuiltins adds patterns that apply at any level of any directory tree, with a lower precedence than all ignore files
+func (ig *Ignorer) AddBuiltins(patterns ...string) {
+	for _, pattern := range patterns {
+		ig.addPattern(pattern, "", "", levelBuiltin)
+	}
+}
+
+// AddPattern adds a single gitignore pattern, relative to the given directory.
+// If dir is empty, the pattern applies at any level of any directory tree.
+func (ig *Ignorer) AddPattern(dir, pattern string) {
+	base := ""
+	if dir != "" {
+		base = ig.absSlash(dir)
+	}
+	ig.addPattern(pattern, base, "", levelIgnoreFile+2*strings.Count(base, "/"))
+}
+
+// AddExcludes adds patterns relative to the given directory, which take precedence over all ignore files
+func (ig *Ignorer) AddExcludes(dir string, patterns ...string) {
+	base := ig.absSlash(dir)
+	for _, pattern := range patterns {
+		ig.addPattern(pattern, base, "", levelExclude)
+	}
+}
+
+// AddFile reads the patterns in an ignore file, like a .gitignore file. The patterns are relative to the directory of the file.
+// Reading the same file more than once has no effect.
+func (ig *Ignorer) AddFile(filename string) error {
+	base := ig.absSlash(filepath.Dir(filename))
+	level := levelIgnoreFile + 2*strings.Count(base, "/")
+	if filepath.Base(filename) == ".ignore" {
+		level++ // .ignore files take precedence over .gitignore files in the same directory
+	}
+	return ig.addFile(filename, base, level)
+}
+
+// AddGitExcludes reads the global excludes file of the user and the .git/info/exclude file of the repository at repoDir, if they exist
+func (ig *Ignorer) AddGitExcludes(repoDir string) {
+	base := ig.absSlash(repoDir)
+	if excludesFile := globalExcludesFile(); excludesFile != "" {
+		ig.addFile(excludesFile, base, levelGlobalExclude)
+	}
+	ig.addFile(filepath.Join(repoDir, ".git", "info", "exclude"), base, levelInfoExclude)
+}
+
+// addFile reads the patterns in an ignore file, relative to the given base directory
+func (ig *Ignorer) addFile(filename, base string, level int) error {
+	key := ig.absSlash(filename)
+	if ig.loaded[key] {
+		return nil
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	ig.loaded[key] = true
+	before := len(ig.patterns)
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		ig.addPattern(scanner.Text(), base, filename, level)
+	}
+	ig.files = append(ig.files, IgnoreFile{Path: filename, Patterns: len(ig.patterns) - before})
+	return scanner.Err()
+}
+
+// Files returns the ignore files that have been read, in the order they were read
+func (ig *Ignorer) Files() []IgnoreFile {
+	if ig == nil {
+		return nil
+	}
+	return ig.files
+}
+
+// addPattern parses a line from an ignore file and adds it as a pattern, if it is not blank or a comment
+func (ig *Ignorer) addPattern(line, base, source string, level int) {
+	line = strings.TrimRight(line, "\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return
+	}
+	// Trailing spaces are ignored, unless they are escaped with a backslash
+	trimmed := strings.TrimRight(line, " ")
+	if len(trimmed) < len(line) && strings.HasSuffix(trimmed, `\`) {
+		trimmed += " "
+	}
+	p := IgnorePattern{Pattern: trimmed, Source: source, level: level}
+	if strings.HasPrefix(trimmed, "!") {
+		p.negate = true
+		trimmed = trimmed[1:]
+	}
+	if strings.HasSuffix(trimmed, "/") {
+		p.dirOnly = true
+		trimmed = strings.TrimRight(trimmed, "/")
+	}
+	if trimmed == "" {
+		return
+	}
+	// A pattern with a slash at the beginning or in the middle is relative to the base directory
+	p.basename = !strings.Contains(trimmed, "/")
+	trimmed = strings.TrimPrefix(trimmed, "/")
+	expr := "^" + globToRegexp(trimmed) + "$"
+	if base == "" && !p.basename {
+		expr = "^(?:.*/)?" + globToRegexp(trimmed) + "$"
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return // skip patterns that can not be parsed
+	}
+	p.re = re
+	if base != "" {
+		p.base = withTrailingSlash(base)
+	}
+	ig.patterns = append(ig.patterns, p)
+}
+
+// Patterns returns all patterns that have been added, in the order they were added
+func (ig *Ignorer) Patterns() []IgnorePattern {
+	if ig == nil {
+		return nil
+	}
+	return ig.patterns
+}
+
+// Match checks if the given path is ignored by the patterns, without checking if any of its parent directories are ignored
+func (ig *Ignorer) Match(p string, isDir bool) bool {
+	if ig == nil {
+		return false
+	}
+	abs := ig.absSlash(p)
+	name := path.Base(abs)
+	ignored, bestLevel := false, -1
+	for i := range ig.patterns {
+		pattern := &ig.patterns[i]
+		if pattern.level < bestLevel || (pattern.dirOnly && !isDir) {
+			continue
+		}
+		subject := name
+		if pattern.base != "" {
+			if !strings.HasPrefix(abs, pattern.base) {
+				continue
 			}
+			if !pattern.basename {
+				subject = abs[len(pattern.base):]
+			}
+		} else if !pattern.basename {
+			subject = strings.TrimPrefix(abs, "/")
+		}
+		if pattern.re.MatchString(subject) {
+			ignored, bestLevel = !pattern.negate, pattern.level
 		}
 	}
-	// Add common ignores typically found in projects
-	commonIgnores := []string{"vendor", "test", "tmp", "backup", "node_modules", "target", ".mvn", ".gradle", ".git"}
-	for _, dir := range commonIgnores {
-		ignores[dir] = struct{}{}
+	return ignored
+}
+
+// Ignored checks if the given path is ignored, either by itself or because one of its parent directories,
+// up to the root of the Ignorer, is ignored. As with git, a file can not be re-included if a parent directory is excluded.
+func (ig *Ignorer) Ignored(p string, isDir bool) bool {
+	if ig == nil {
+		return false
 	}
-	return ignores, nil
+	abs := ig.absSlash(p)
+	if strings.HasPrefix(abs, withTrailingSlash(ig.root)) {
+		rel := abs[len(withTrailingSlash(ig.root)):]
+		for i := strings.Index(rel, "/"); i >= 0; i = nextSlash(rel, i) {
+			if ig.Match(path.Join(ig.root, rel[:i]), true) {
+				return true
+			}
+		}
+	}
+	return ig.Match(abs, isDir)
+}
+
+// nextSlash returns the index of the next slash in s after index i, or -1
+func nextSlash(s string, i int) int {
+	j := strings.Index(s[i+1:], "/")
+	if j < 0 {
+		return -1
+	}
+	return i + 1 + j
+}
+
+// ShouldSkip determines if a file or directory should be skipped based on ignore patterns
+func ShouldSkip(path string, ignores *Ignorer) bool {
+	return ignores.Ignored(path, isDir(path))
+}
+
+// globToRegexp converts a gitignore glob to a regular expression, where wildcards do not match slashes,
+// and "**" matches any number of directories when it is a whole path component
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			j := i
+			for j < len(glob) && glob[j] == '*' {
+				j++
+			}
+			if j-i == 2 && (i == 0 || glob[i-1] == '/') && (j == len(glob) || glob[j] == '/') {
+				if j == len(glob) {
+					sb.WriteString(".*") // everything inside
+				} else {
+					sb.WriteString("(?:.*/)?") // zero or more directories
+					j++
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+			i = j - 1
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			class, n := globClass(glob[i:])
+			if n == 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			sb.WriteString(class)
+			i += n - 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+			}
+			sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	return sb.String()
 }
 
-// ShouldSkip determines if a directory should be skipped based on ignore patterns
-func ShouldSkip(path string, ignores map[string]struct{}) bool {
-	for ignore := range ignores {
-		if matched, _ := filepath.Match(ignore, filepath.Base(path)); matched {
-			return true
+// globClass converts a bracket expression like "[a-z]" or "[!0-9]" at the start of s to a regular expression character class.
+// It returns the character class and the number of bytes consumed from s, or 0 if s does not start with a complete bracket expression.
+func globClass(s string) (string, int) {
+	var sb strings.Builder
+	i := 1
+	if i < len(s) && (s[i] == '!' || s[i] == '^') {
+		sb.WriteString("[^/")
+		i++
+	} else {
+		sb.WriteString("[")
+	}
+	for first := true; i < len(s); first = false {
+		c := s[i]
+		switch {
+		case c == ']' && !first:
+			sb.WriteString("]")
+			return sb.String(), i + 1
+		case c == '[' && strings.HasPrefix(s[i:], "[:"):
+			end := strings.Index(s[i:], ":]")
+			if end < 0 {
+				return "", 0
+			}
+			sb.WriteString(s[i : i+end+2]) // a character class like [:alpha:]
+			i += end + 2
+			continue
+		case c == '\\' && i+1 < len(s):
+			i++
+			sb.WriteString(`\` + s[i:i+1])
+		case c == '\\' || c == ']' || c == '[' || c == '^':
+			sb.WriteString(`\` + string(c))
+		default:
+			sb.WriteByte(c)
 		}
-		if strings.HasPrefix(path, ignore+string(filepath.Separator)) {
-			return true
+		i++
+	}
+	return "", 0
+}
+
+// globalExcludesFile returns the path to the global git excludes file of the user, as configured with core.excludesFile
+func globalExcludesFile() string {
+	if value := readGlobalGitConfig().Get("core.excludesfile"); value != "" {
+		return expandHome(value)
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
 		}
+		configHome = filepath.Join(home, ".config")
 	}
-	return false
+	return filepath.Join(configHome, "git", "ignore")
 }
@@ -0,0 +1,183 @@
+package projectinfo
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestIgnorerMatch(t *testing.T) {
+	root := t.TempDir()
+	ig := NewIgnorer(root)
+	for _, pattern := range []string{
+		"# a comment",
+		"*.log",
+		"!keep.log",
+		"/build",
+		"logs/",
+		"docs/**/*.tmp",
+		"**/generated",
+		"cache/**",
+		`\#hash`,
+		"[abc].txt",
+		"[!x]y.txt",
+	} {
+		ig.AddPattern(root, pattern)
+	}
+
+	testCases := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"app.log", false, true},
+		{"sub/app.log", false, true},
+		{"keep.log", false, false},
+		{"sub/keep.log", false, false},
+		{"build", true, true},
+		{"build", false, true},
+		{"sub/build", true, false},
+		{"logs", true, true},
+		{"logs", false, false},
+		{"sub/logs", true, true},
+		{"docs/a.tmp", false, true},
+		{"docs/x/y/a.tmp", false, true},
+		{"other/a.tmp", false, false},
+		{"generated", true, true},
+		{"a/b/generated", true, true},
+		{"cache", true, false},
+		{"cache/x/y", false, true},
+		{"#hash", false, true},
+		{"a.txt", false, true},
+		{"d.txt", false, false},
+		{"zy.txt", false, true},
+		{"xy.txt", false, false},
+		{"# a comment", false, false},
+		{"main.go", false, false},
+	}
+	for _, tc := range testCases {
+		if got := ig.Match(filepath.Join(root, tc.path), tc.isDir); got != tc.want {
+			t.Errorf("Match(%q, %v) = %v, want %v", tc.path, tc.isDir, got, tc.want)
+		}
+	}
+}
+
+func TestIgnorerIgnored(t *testing.T) {
+	root := t.TempDir()
+	ig := NewIgnorer(root)
+	ig.AddPattern(root, "out/")
+	ig.AddPattern(root, "!out/keep.go")
+	if !ig.Ignored(filepath.Join(root, "out", "keep.go"), false) {
+		t.Error("a file in an excluded directory should stay excluded, even when it is negated")
+	}
+	if ig.Ignored(filepath.Join(root, "src", "main.go"), false) {
+		t.Error("src/main.go should not be ignored")
+	}
+}
+
+func TestCollectFilesNestedGitignore(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".gitignore":          "*.gen.go\n/only_root.go\n",
+		".git/info/exclude":   "excluded.go\n",
+		"main.go":             "package main\n",
+		"only_root.go":        "package main\n",
+		"excluded.go":         "package main\n",
+		"api.gen.go":          "package main\n",
+		"sub/.gitignore":      "!keep.gen.go\nlocal.go\n",
+		"sub/only_root.go":    "package sub\n",
+		"sub/keep.gen.go":     "package sub\n",
+		"sub/other.gen.go":    "package sub\n",
+		"sub/local.go":        "package sub\n",
+		"other/local.go":      "package other\n",
+		"other/excluded.go":   "package other\n",
+		"sub/deeper/local.go": "package deeper\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	collected, err := CollectFiles(root, NewIgnorer(root), false, false, false)
+	if err != nil {
+		t.Fatalf("CollectFiles() error = %v", err)
+	}
+	var got []string
+	for _, file := range collected {
+		rel, _ := filepath.Rel(root, file.Path)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	sort.Strings(got)
+	want := []string{"main.go", "other/local.go", "sub/keep.gen.go", "sub/only_root.go"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("CollectFiles() collected %v, want %v", got, want)
+	}
+}
+
+func TestIgnoreOptions(t *testing.T) {
+	testCases := []struct {
+		name    string
+		opts    IgnoreOptions
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{name: "test directories are kept", opts: IgnoreOptions{}, path: "test", isDir: true, ignored: false},
+		{name: "defaults", opts: IgnoreOptions{}, path: "node_modules", isDir: true, ignored: true},
+		{name: "removed default", opts: IgnoreOptions{Remove: []string{"vendor"}}, path: "vendor", isDir: true, ignored: false},
+		{name: "no defaults", opts: IgnoreOptions{NoDefaults: true}, path: "node_modules", isDir: true, ignored: false},
+		{name: "added pattern", opts: IgnoreOptions{Add: []string{"fixtures/"}}, path: "a/fixtures", isDir: true, ignored: true},
+		{name: "python preset", opts: IgnoreOptions{Presets: []string{"python"}}, path: "pkg/__pycache__", isDir: true, ignored: true},
+		{name: "javascript preset", opts: IgnoreOptions{Presets: []string{"JavaScript"}}, path: "web/.next", isDir: true, ignored: true},
+		{name: "preset patterns can be removed", opts: IgnoreOptions{Presets: []string{"javascript"}, Remove: []string{"dist/"}}, path: "dist", isDir: true, ignored: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ignores, err := LoadIgnorePatternsWithOptions(tc.opts)
+			if err != nil {
+				t.Fatalf("LoadIgnorePatternsWithOptions() error = %v", err)
+			}
+			if got := ignores.Match(tc.path, tc.isDir); got != tc.ignored {
+				t.Errorf("Match(%q) = %v, want %v", tc.path, got, tc.ignored)
+			}
+		})
+	}
+	if _, err := LoadIgnorePatternsWithOptions(IgnoreOptions{Presets: []string{"cobol"}}); err == nil {
+		t.Error("expected an error for an unknown preset")
+	}
+}
+
+func TestVCSDirsAlwaysSkipped(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		".git/objects/ab/cdef0123": "#!/bin/sh\necho not a source file\n",
+		".hg/hgrc":                 "#!/bin/sh\n",
+		"sub/.git":                 "gitdir: ../.git/modules/sub\n",
+		"main.go":                  "package main\n",
+	}
+	for filename, content := range files {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(filename)), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := setupMockFile(dir, filename, content); err != nil {
+			t.Fatalf("Failed to write %s: %v", filename, err)
+		}
+	}
+	for _, ignore := range []IgnoreOptions{{}, {NoDefaults: true}, {Remove: []string{".git", ".hg"}}} {
+		project, err := NewWithOptions(dir, Options{Ignore: ignore, NoGit: true, NoAPIServerCheck: true})
+		if err != nil {
+			t.Fatalf("NewWithOptions() error = %v", err)
+		}
+		files := project.AllFiles()
+		if len(files) != 1 || filepath.Base(files[0].Path) != "main.go" {
+			t.Errorf("with %+v, collected %v, want only main.go", ignore, files)
+		}
+	}
+}
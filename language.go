@@ -6,76 +6,59 @@ import (
 	"unicode"
 )
 
+// File categories, as returned by FileCategory
+const (
+	CategorySource        = "source"
+	CategoryDocumentation = "documentation"
+	CategoryConfiguration = "configuration"
+	CategoryBuild         = "build"
+	CategoryOther         = "other"
+)
+
+// FileCategory classifies a file as source code, documentation, configuration, a build file, data or something else, based on its name and extension
+func FileCategory(path string) string {
+	if language, ok := DefaultLanguages.ForPath(path); ok {
+		return language.Category
+	}
+	return CategoryOther
+}
+
+// isDocOrConfCategory checks if the given category belongs with the documentation and configuration files
+func isDocOrConfCategory(category string) bool {
+	return category == CategoryDocumentation || category == CategoryConfiguration || category == CategoryBuild || category == CategoryData
+}
+
 // RecognizedExtension checks if the file extension is recognized and should be included based on the docAndConf flag
 func RecognizedExtension(path string, docAndConf bool) bool {
-	ext := strings.ToLower(filepath.Ext(path))
-	switch ext {
-	case ".rst", ".txt", ".adoc", ".md", ".yml", ".yaml", ".properties": // documentation and configuration
-		return docAndConf // return true only if catalogFlag is set to true
-	case ".c", ".cc", ".cpp", ".cs", ".go", ".h", ".hpp", ".hs", ".java", ".js", ".jsx", ".kt", ".py", ".rs", ".ts", ".tsx", ".sql": // source code only
-		return !docAndConf // return true only if catalogFlag is set to false
+	category := FileCategory(filepath.Ext(path))
+	if docAndConf {
+		return isDocOrConfCategory(category) // return true only for documentation and configuration files
 	}
-	return false
+	return category == CategorySource // return true only for source code
 }
 
 // RecognizedFilename checks if the given path is a recgonized filename, like "LICENSE"
 func RecognizedFilename(path string, docAndConf bool) bool {
-	if !docAndConf {
+	if !docAndConf || filepath.Ext(path) != "" {
 		return false
 	}
-	switch strings.ToLower(filepath.Base(path)) {
-	case "copying", "license", "notice", "makefile":
-		return true
-	}
-	return false
+	return isDocOrConfCategory(FileCategory(path))
 }
 
-// LanguageFromExtension determines the programming language from the file extension
+// LanguageFromExtension determines the programming language from the file extension, like ".go", ".GO" or ".d.ts"
 func LanguageFromExtension(ext string) string {
-	switch ext {
-	case ".adoc":
-		return "ASCIIDoc"
-	case ".c":
-		return "C"
-	case ".cpp", ".cc":
-		return "C++"
-	case ".cs":
-		return "C#"
-	case ".go":
-		return "Go"
-	case ".hpp", ".h":
-		return "C/C++ Header"
-	case ".hs":
-		return "Haskell"
-	case ".java":
-		return "Java"
-	case ".js", ".jsx":
-		return "JavaScript"
-	case ".kt":
-		return "Kotlin"
-	case ".md":
-		return "Markdown"
-	case ".properties":
-		return "Properties"
-	case ".py":
-		return "Python"
-	case ".rs":
-		return "Rust"
-	case ".rst":
-		return "reStructuredText"
-	case ".sql":
-		return "SQL"
-	case ".ts", ".tsx":
-		return "TypeScript"
-	case ".txt":
-		return "Plain text"
-	case ".xml":
-		return "XML"
-	case ".yml", ".yaml":
-		return "YAML"
-	default:
-		return "Unknown"
+	if language, ok := DefaultLanguages.ForExtension(ext); ok {
+		return language.Name
+	}
+	return "Unknown"
+}
+
+// LanguageFromPath determines the language of a file from its name, like "Makefile", or else from its extension
+func LanguageFromPath(path string) string {
+	if language, ok := DefaultLanguages.ForPath(path); ok {
+		return language.Name
 	}
+	return "Unknown"
 }
 
 // DetectProjectType determines the most common programming language used in the project files to suggest the project's type
@@ -87,7 +70,7 @@ func DetectProjectType(files []FileInfo) string {
 	maxCount := 0
 	projectType := "Unrecognized"
 	for lang, count := range languageCount {
-		if count > maxCount {
+		if count > maxCount || (count == maxCount && lang < projectType) { // ties go to the first name, so that the result does not depend on the map order
 			maxCount = count
 			projectType = lang
 		}
@@ -105,9 +88,8 @@ func OptimizeCode(source string, ext string) string {
 		trimLeft         = true // default to trimming whitespace from both ends
 	)
 
-	switch ext {
-	case ".py", ".hs": // Python, Haskell might need to preserve significant leading spaces
-		trimLeft = false
+	if language, ok := DefaultLanguages.ForExtension(ext); ok && language.SignificantWhitespace {
+		trimLeft = false // like Python and Haskell, where leading spaces need to be preserved
 	}
 
 	for _, line := range lines {
@@ -0,0 +1,269 @@
+package projectinfo
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestFileCategory(t *testing.T) {
+	testCases := []struct {
+		path string
+		want string
+	}{
+		{"main.go", CategorySource},
+		{"src/App.TSX", CategorySource},
+		{"README.md", CategoryDocumentation},
+		{"LICENSE", CategoryDocumentation},
+		{"config/app.yaml", CategoryConfiguration},
+		{"Makefile", CategoryBuild},
+		{"pom.xml", CategoryBuild},
+		{"data/items.xml", CategoryData},
+		{"logo.png", CategoryOther},
+	}
+	for _, tc := range testCases {
+		if got := FileCategory(tc.path); got != tc.want {
+			t.Errorf("FileCategory(%q) = %q, want %q", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestLanguageRegistry(t *testing.T) {
+	testCases := []struct {
+		path     string
+		language string
+	}{
+		{"main.go", "Go"},
+		{"Makefile", "Makefile"},
+		{"GNUmakefile", "Makefile"},
+		{"LICENSE", "Plain text"},
+		{"layout.xml", "XML"},
+		{"logo.png", "Unknown"},
+	}
+	for _, tc := range testCases {
+		if got := LanguageFromPath(tc.path); got != tc.language {
+			t.Errorf("LanguageFromPath(%q) = %q, want %q", tc.path, got, tc.language)
+		}
+	}
+	if !RecognizedExtension("layout.xml", true) {
+		t.Error("RecognizedExtension(\"layout.xml\", true) = false, want true")
+	}
+
+	registry, err := NewLanguageRegistry()
+	if err != nil {
+		t.Fatalf("NewLanguageRegistry() error = %v", err)
+	}
+	if language, ok := registry.Lookup("GOLANG"); !ok || language.Name != "Go" || language.LineComment != "//" {
+		t.Errorf("Lookup(\"GOLANG\") = %+v, %v", language, ok)
+	}
+	if language, ok := registry.ForInterpreter("/usr/bin/python3"); !ok || language.Name != "Python" {
+		t.Errorf("ForInterpreter(\"/usr/bin/python3\") = %+v, %v", language, ok)
+	}
+	if err := registry.Add(Language{Name: "Nothing", Category: "unknown"}); err == nil {
+		t.Error("Add() with an invalid category did not return an error")
+	}
+
+	// Languages from a file take precedence over the built-in ones
+	filename := filepath.Join(t.TempDir(), "languages.json")
+	if err := setupMockFile(filepath.Dir(filename), filepath.Base(filename), `[{"name": "Objective-C", "category": "source", "extensions": ["h", ".m"], "lineComment": "//"}]`); err != nil {
+		t.Fatalf("Failed to write the languages file: %v", err)
+	}
+	if err := registry.LoadFile(filename); err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	if language, ok := registry.ForPath("include/util.h"); !ok || language.Name != "Objective-C" {
+		t.Errorf("ForPath(\"include/util.h\") = %+v, %v, want Objective-C", language, ok)
+	}
+	if language, _ := DefaultLanguages.ForPath("include/util.h"); language.Name != "C/C++ Header" {
+		t.Errorf("the default registry was changed, and gives %q for util.h", language.Name)
+	}
+
+	// The registry can be given in the options
+	dir := t.TempDir()
+	for _, filename := range []string{"main.m", "Makefile", "main.go"} {
+		if err := setupMockFile(dir, filename, "x\n"); err != nil {
+			t.Fatalf("Failed to write %s: %v", filename, err)
+		}
+	}
+	project, err := NewWithOptions(dir, Options{NoGit: true, NoAPIServerCheck: true, Languages: registry})
+	if err != nil {
+		t.Fatalf("NewWithOptions() error = %v", err)
+	}
+	var languages []string
+	for _, file := range append(project.SourceFiles, project.ConfAndDocFiles...) {
+		languages = append(languages, file.Language)
+	}
+	sort.Strings(languages)
+	if want := []string{"Go", "Makefile", "Objective-C"}; !reflect.DeepEqual(languages, want) {
+		t.Errorf("languages = %v, want %v", languages, want)
+	}
+}
+
+func TestOptimizeCodeIndentation(t *testing.T) {
+	source := "def f():\n    return 1\n\n\n"
+	if got, want := OptimizeCode(source, ".py"), "def f():\n    return 1\n"; got != want {
+		t.Errorf("OptimizeCode(%q, \".py\") = %q, want %q", source, got, want)
+	}
+	if got, want := OptimizeCode("func f() {\n    return\n}", ".go"), "func f() {\nreturn\n}"; got != want {
+		t.Errorf("OptimizeCode for Go = %q, want %q", got, want)
+	}
+}
+
+func TestLanguageCoverage(t *testing.T) {
+	testCases := []struct {
+		path     string
+		language string
+		category string
+	}{
+		{"app/models/user.rb", "Ruby", CategorySource},
+		{"Gemfile", "Gemfile", CategoryConfiguration},
+		{"app.gemspec", "Gemfile", CategoryConfiguration},
+		{"Rakefile", "Rake", CategoryBuild},
+		{"lib/tasks/db.rake", "Rake", CategoryBuild},
+		{"index.php", "PHP", CategorySource},
+		{"Sources/App.swift", "Swift", CategorySource},
+		{"build.sc", "Scala", CategorySource},
+		{"scripts/deploy.sh", "Shell", CategorySource},
+		{"init.lua", "Lua", CategorySource},
+		{"build.zig", "Zig", CategorySource},
+		{"src/app.nim", "Nim", CategorySource},
+		{"lib/app.ex", "Elixir", CategorySource},
+		{"src/app.erl", "Erlang", CategorySource},
+		{"src/core.clj", "Clojure", CategorySource},
+		{"resources/config.edn", "EDN", CategoryData},
+		{"bin/main.ml", "OCaml", CategorySource},
+		{"Program.fs", "F#", CategorySource},
+		{"lib/main.dart", "Dart", CategorySource},
+		{"analysis.r", "R", CategorySource},
+		{"src/App.jl", "Julia", CategorySource},
+		{"script.pl", "Perl", CategorySource},
+		{"AppDelegate.m", "Objective-C", CategorySource},
+		{"App.vue", "Vue", CategorySource},
+		{"App.svelte", "Svelte", CategorySource},
+		{"index.html", "HTML", CategorySource},
+		{"style.css", "CSS", CategorySource},
+		{"main.tf", "Terraform", CategorySource},
+		{"prod.tfvars", "HCL", CategoryConfiguration},
+		{"src/App.groovy", "Groovy", CategorySource},
+		{"build.gradle", "Gradle", CategoryBuild},
+		{"build.gradle.kts", "Gradle", CategoryBuild},
+		{"src/App.kts", "Kotlin", CategorySource},
+		{"api/service.proto", "Protocol Buffers", CategorySource},
+		{"Dockerfile", "Dockerfile", CategoryBuild},
+		{"CMakeLists.txt", "CMake", CategoryBuild},
+		{"Cargo.toml", "TOML", CategoryConfiguration},
+		{"notes.txt", "Plain text", CategoryDocumentation},
+	}
+	for _, tc := range testCases {
+		if got := LanguageFromPath(tc.path); got != tc.language {
+			t.Errorf("LanguageFromPath(%q) = %q, want %q", tc.path, got, tc.language)
+		}
+		if got := FileCategory(tc.path); got != tc.category {
+			t.Errorf("FileCategory(%q) = %q, want %q", tc.path, got, tc.category)
+		}
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"app.rb":          "puts 'hi'\n",
+		"lib/helpers.rb":  "module Helpers\nend\n",
+		"bin/setup.sh":    "#!/bin/sh\n",
+		"Dockerfile":      "FROM ruby\n",
+		"config/app.yaml": "a: b\n",
+	}
+	for filename, content := range files {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(filename)), 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := setupMockFile(dir, filename, content); err != nil {
+			t.Fatalf("Failed to write %s: %v", filename, err)
+		}
+	}
+	project, err := NewWithOptions(dir, Options{NoGit: true, NoAPIServerCheck: true})
+	if err != nil {
+		t.Fatalf("NewWithOptions() error = %v", err)
+	}
+	if project.Type != "Ruby" {
+		t.Errorf("project type = %q, want Ruby", project.Type)
+	}
+	if len(project.SourceFiles) != 3 || len(project.ConfAndDocFiles) != 2 {
+		t.Errorf("got %d source files and %d other files, want 3 and 2", len(project.SourceFiles), len(project.ConfAndDocFiles))
+	}
+}
+
+func TestCaseInsensitiveLanguages(t *testing.T) {
+	testCases := []struct {
+		path     string
+		language string
+		category string
+	}{
+		{"Main.JAVA", "Java", CategorySource},
+		{"README.MD", "Markdown", CategoryDocumentation},
+		{"Readme.Markdown", "Markdown", CategoryDocumentation},
+		{"src/App.TSX", "TypeScript", CategorySource},
+		{"Program.Cs", "C#", CategorySource},
+		{"CONFIG.YML", "YAML", CategoryConfiguration},
+		{"MAKEFILE", "Makefile", CategoryBuild},
+		{"cmakelists.TXT", "CMake", CategoryBuild},
+		{"types/index.d.ts", "TypeScript", CategorySource},
+		{"src/Button.test.tsx", "TypeScript", CategorySource},
+		{"static/jquery.min.js", "JavaScript", CategorySource},
+		{"service.pb.go", "Go", CategorySource},
+		{".eslintrc.json", "JSON", CategoryConfiguration},
+		{"release/app-1.2.3.tar.gz", "Unknown", CategoryOther},
+		{"backup.TAR.GZ", "Unknown", CategoryOther},
+		{"v1.2.3", "Unknown", CategoryOther},
+	}
+	for _, tc := range testCases {
+		if got := LanguageFromPath(tc.path); got != tc.language {
+			t.Errorf("LanguageFromPath(%q) = %q, want %q", tc.path, got, tc.language)
+		}
+		if got := FileCategory(tc.path); got != tc.category {
+			t.Errorf("FileCategory(%q) = %q, want %q", tc.path, got, tc.category)
+		}
+		if ext := filepath.Ext(tc.path); ext != "" && FileCategory(tc.path) == CategorySource {
+			if got := LanguageFromExtension(ext); got != tc.language {
+				t.Errorf("LanguageFromExtension(%q) = %q, want %q", ext, got, tc.language)
+			}
+			if !RecognizedExtension(tc.path, false) {
+				t.Errorf("RecognizedExtension(%q, false) = false, want true", tc.path)
+			}
+		}
+	}
+
+	// The longest known extension wins
+	registry, err := NewLanguageRegistry()
+	if err != nil {
+		t.Fatalf("NewLanguageRegistry() error = %v", err)
+	}
+	if err := registry.Add(Language{Name: "TypeScript Declarations", Category: CategorySource, Extensions: []string{".D.TS"}}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	for path, want := range map[string]string{"index.d.ts": "TypeScript Declarations", "INDEX.D.TS": "TypeScript Declarations", "index.ts": "TypeScript", "d.ts": "TypeScript"} {
+		if language, _ := registry.ForPath(path); language.Name != want {
+			t.Errorf("ForPath(%q) = %q, want %q", path, language.Name, want)
+		}
+	}
+
+	// Files with mixed-case extensions are collected
+	dir := t.TempDir()
+	for _, filename := range []string{"Main.JAVA", "README.MD", "index.d.ts", "backup.tar.gz"} {
+		if err := setupMockFile(dir, filename, "x\n"); err != nil {
+			t.Fatalf("Failed to write %s: %v", filename, err)
+		}
+	}
+	project, err := NewWithOptions(dir, Options{NoGit: true, NoAPIServerCheck: true})
+	if err != nil {
+		t.Fatalf("NewWithOptions() error = %v", err)
+	}
+	var collected []string
+	for _, file := range append(project.SourceFiles, project.ConfAndDocFiles...) {
+		collected = append(collected, filepath.Base(file.Path)+": "+file.Language)
+	}
+	sort.Strings(collected)
+	if want := []string{"Main.JAVA: Java", "README.MD: Markdown", "index.d.ts: TypeScript"}; !reflect.DeepEqual(collected, want) {
+		t.Errorf("collected %v, want %v", collected, want)
+	}
+}
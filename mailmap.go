@@ -0,0 +1,209 @@
+package projectinfo
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// gitIdentity is the name and email address of a commit author
+type gitIdentity struct {
+	Name  string
+	Email string
+}
+
+// String formats the identity like "Jane Doe <jane@example.com>"
+func (identity gitIdentity) String() string {
+	if identity.Email == "" {
+		return identity.Name
+	}
+	return identity.Name + " <" + identity.Email + ">"
+}
+
+// mailmapEntry is a line in a .mailmap file. Empty proper fields are left as they are,
+// and an empty commit name matches any name.
+type mailmapEntry struct {
+	properName  string
+	properEmail string
+	commitName  string
+	commitEmail string
+}
+
+// Mailmap maps the names and email addresses that are used in commits to the canonical ones, as configured in a .mailmap file
+type Mailmap struct {
+	entries []mailmapEntry
+}
+
+// ParseMailmap parses the contents of a .mailmap file, where each line is one of:
+//
+//	Proper Name <commit@email>
+//	<proper@email> <commit@email>
+//	Proper Name <proper@email> <commit@email>
+//	Proper Name <proper@email> Commit Name <commit@email>
+func ParseMailmap(contents string) *Mailmap {
+	m := &Mailmap{}
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name1, email1, rest, ok := cutMailmapIdentity(line)
+		if !ok {
+			continue
+		}
+		entry := mailmapEntry{properName: name1, commitEmail: email1}
+		if name2, email2, _, ok := cutMailmapIdentity(rest); ok {
+			entry = mailmapEntry{properName: name1, properEmail: email1, commitName: name2, commitEmail: email2}
+		}
+		m.entries = append(m.entries, entry)
+	}
+	return m
+}
+
+// cutMailmapIdentity parses an optional name followed by an email address in angle brackets, and returns what is left of the line
+func cutMailmapIdentity(s string) (name, email, rest string, ok bool) {
+	lt := strings.Index(s, "<")
+	gt := strings.Index(s, ">")
+	if lt < 0 || gt < lt {
+		return "", "", s, false
+	}
+	return strings.TrimSpace(s[:lt]), strings.TrimSpace(s[lt+1 : gt]), s[gt+1:], true
+}
+
+// LoadMailmap reads a .mailmap file. A file that does not exist gives an empty mailmap.
+func LoadMailmap(filename string) (*Mailmap, error) {
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Mailmap{}, nil
+	}
+	if err != nil {
+		return &Mailmap{}, err
+	}
+	return ParseMailmap(string(data)), nil
+}
+
+// Resolve returns the canonical name and email address for a name and email address used in a commit.
+// Like git, email addresses and names are compared case-insensitively, and an entry with a matching name is preferred.
+func (m *Mailmap) Resolve(name, email string) (string, string) {
+	if m == nil {
+		return name, email
+	}
+	var match *mailmapEntry
+	for i := range m.entries {
+		entry := &m.entries[i]
+		if !strings.EqualFold(entry.commitEmail, email) {
+			continue
+		}
+		if entry.commitName == "" {
+			if match == nil {
+				match = entry
+			}
+		} else if strings.EqualFold(entry.commitName, name) {
+			match = entry
+			break
+		}
+	}
+	if match == nil {
+		return name, email
+	}
+	if match.properName != "" {
+		name = match.properName
+	}
+	if match.properEmail != "" {
+		email = match.properEmail
+	}
+	return name, email
+}
+
+// githubNoreply matches the private commit email addresses of GitHub, like "12345+login@users.noreply.github.com"
+var githubNoreply = regexp.MustCompile(`(?i)^(?:\d+\+)?([^@]+)@users\.noreply\.github\.com$`)
+
+// identityKeys returns the keys that heuristically identify the same person: the email address, the name ignoring case,
+// and the GitHub login for GitHub noreply addresses
+func identityKeys(identity gitIdentity) []string {
+	var keys []string
+	if name := strings.ToLower(strings.TrimSpace(identity.Name)); name != "" {
+		keys = append(keys, "name:"+name)
+	}
+	if m := githubNoreply.FindStringSubmatch(identity.Email); m != nil {
+		login := strings.ToLower(m[1])
+		keys = append(keys, "github:"+login, "name:"+login)
+	} else if email := strings.ToLower(strings.TrimSpace(identity.Email)); email != "" {
+		keys = append(keys, "email:"+email)
+	}
+	return keys
+}
+
+// resolveIdentities maps each identity that is used in commits to a canonical identity.
+// The mailmap is applied first. If merge is true, identities that share an email address, a name that differs only in case
+// or a GitHub login are then merged, and the identity with the most commits in each group becomes the canonical one.
+// The counts are the number of commits for each identity.
+func resolveIdentities(counts map[gitIdentity]int, mailmap *Mailmap, merge bool) map[gitIdentity]gitIdentity {
+	mapped := make(map[gitIdentity]gitIdentity, len(counts))
+	mappedCounts := make(map[gitIdentity]int)
+	for identity, count := range counts {
+		name, email := mailmap.Resolve(identity.Name, identity.Email)
+		mapped[identity] = gitIdentity{name, email}
+		mappedCounts[gitIdentity{name, email}] += count
+	}
+	if !merge {
+		return mapped
+	}
+
+	// Sort the identities, so that the result does not depend on the map order
+	identities := make([]gitIdentity, 0, len(mappedCounts))
+	for identity := range mappedCounts {
+		identities = append(identities, identity)
+	}
+	sort.Slice(identities, func(i, j int) bool {
+		a, b := identities[i], identities[j]
+		if mappedCounts[a] != mappedCounts[b] {
+			return mappedCounts[a] > mappedCounts[b]
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Email < b.Email
+	})
+
+	// Union the identities that share a key, with the identity that comes first as the root of each group
+	parent := make([]int, len(identities))
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	byKey := make(map[string]int)
+	for i, identity := range identities {
+		parent[i] = i
+		for _, key := range identityKeys(identity) {
+			j, ok := byKey[key]
+			if !ok {
+				byKey[key] = i
+				continue
+			}
+			if a, b := find(i), find(j); a != b {
+				if a < b {
+					parent[b] = a
+				} else {
+					parent[a] = b
+				}
+			}
+		}
+	}
+	index := make(map[gitIdentity]int, len(identities))
+	for i, identity := range identities {
+		index[identity] = i
+	}
+	for identity, mappedIdentity := range mapped {
+		mapped[identity] = identities[find(index[mappedIdentity])]
+	}
+	return mapped
+}
@@ -0,0 +1,107 @@
+package projectinfo
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMailmapResolve(t *testing.T) {
+	mailmap := ParseMailmap(`# Comment
+Jane Doe <jane@example.com>
+<john@example.com> <john@old.example.com>
+Alice Smith <alice@example.com> <alice@laptop>
+Bob Jones <bob@example.com> bobby <BOB@work.example.com>
+`)
+	testCases := []struct {
+		name, email         string
+		wantName, wantEmail string
+	}{
+		{"jane", "jane@example.com", "Jane Doe", "jane@example.com"},
+		{"John", "JOHN@old.example.com", "John", "john@example.com"},
+		{"alice", "alice@laptop", "Alice Smith", "alice@example.com"},
+		{"Bobby", "bob@work.example.com", "Bob Jones", "bob@example.com"},
+		{"Robert", "bob@work.example.com", "Robert", "bob@work.example.com"},
+		{"Carol", "carol@example.com", "Carol", "carol@example.com"},
+	}
+	for _, tc := range testCases {
+		name, email := mailmap.Resolve(tc.name, tc.email)
+		if name != tc.wantName || email != tc.wantEmail {
+			t.Errorf("Resolve(%q, %q) = %q, %q, want %q, %q", tc.name, tc.email, name, email, tc.wantName, tc.wantEmail)
+		}
+	}
+}
+
+func TestResolveIdentities(t *testing.T) {
+	counts := map[gitIdentity]int{
+		{"Alexander", "alexander@example.com"}:                   5,
+		{"alexander", "alex@home.example.com"}:                   2,
+		{"Alex", "alexander@example.com"}:                        1,
+		{"alexander", "1234+alexander@users.noreply.github.com"}: 1,
+		{"Carol", "carol@example.com"}:                           3,
+	}
+	canonical := gitIdentity{"Alexander", "alexander@example.com"}
+
+	merged := resolveIdentities(counts, nil, true)
+	for identity := range counts {
+		want := canonical
+		if identity.Name == "Carol" {
+			want = identity
+		}
+		if got := merged[identity]; got != want {
+			t.Errorf("merged identity of %v = %v, want %v", identity, got, want)
+		}
+	}
+
+	unmerged := resolveIdentities(counts, nil, false)
+	for identity := range counts {
+		if got := unmerged[identity]; got != identity {
+			t.Errorf("identity of %v without merging = %v, want it unchanged", identity, got)
+		}
+	}
+}
+
+func TestContributorAliases(t *testing.T) {
+	repo := newTestGitRepo(t)
+	a := repo.blob("package a\n")
+	c1 := repo.commit(repo.tree(map[string]gitHash{"a.go": a}), "Alice", 1000)
+	c2 := repo.commit(repo.tree(map[string]gitHash{"a.go": repo.blob("package a // 2\n")}), "alice", 2000, c1)
+	c3 := repo.commit(repo.tree(map[string]gitHash{"a.go": repo.blob("package a // 3\n")}), "Al", 3000, c2)
+	repo.setRef("refs/heads/main", c3)
+	repo.writeFile(".mailmap", "Alice <alice@example.com> Al <al@example.com>\n")
+
+	got, err := GitContributorDetails(repo.dir)
+	if err != nil {
+		t.Fatalf("GitContributorDetails() error = %v", err)
+	}
+	var names []string
+	for _, contributor := range got {
+		names = append(names, contributor.Name)
+	}
+	if want := []string{"Alice", "alice"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("contributors without merging = %v, want %v", names, want)
+	}
+	if want := []string{"Al <al@example.com>"}; !reflect.DeepEqual(got[0].Aliases, want) {
+		t.Errorf("aliases of %s = %v, want %v", got[0].Name, got[0].Aliases, want)
+	}
+
+	project, err := NewWithOptions(repo.dir, Options{MergeIdentities: true, NoAPIServerCheck: true})
+	if err != nil {
+		t.Fatalf("NewWithOptions() error = %v", err)
+	}
+	if len(project.ContributorList) != 1 {
+		t.Fatalf("merged contributors = %+v, want one", project.ContributorList)
+	}
+	alice := project.ContributorList[0]
+	if alice.Name != "Alice" || alice.Email != "alice@example.com" || alice.Commits != 3 {
+		t.Errorf("merged contributor = %+v, want Alice <alice@example.com> with 3 commits", alice)
+	}
+	if want := []string{"Al <al@example.com>", "alice <alice@example.com>"}; !reflect.DeepEqual(alice.Aliases, want) {
+		t.Errorf("aliases of the merged contributor = %v, want %v", alice.Aliases, want)
+	}
+	histories := newGitHistories()
+	defer histories.Close()
+	if got := contributorsForFile(histories, filepath.Join(repo.dir, "a.go")); !reflect.DeepEqual(got, []string{"Alice", "alice"}) {
+		t.Errorf("contributors for a.go = %v, want [Alice alice]", got)
+	}
+}
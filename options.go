@@ -0,0 +1,116 @@
+package projectinfo
+
+import (
+	"log"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Options configures how NewWithOptions gathers information about a project.
+// The zero value gives the same behavior as New, without any log output.
+type Options struct {
+	Include             []string          // glob patterns for the files to collect, like "*.go" or "cmd/**", where "**" matches any number of directories. If empty, all recognized files are collected.
+	Exclude             []string          // gitignore-style patterns for files and directories to skip, relative to the project directory. They take precedence over all ignore files.
+	Ignore              IgnoreOptions     // which built-in ignore patterns to use
+	NoContents          bool              // leave FileInfo.Contents empty. The lines and tokens are still counted.
+	NoGit               bool              // do not read the git history for contributors, line ownership, churn and last commits
+	Blame               bool              // count how many lines of each file every author last changed, for FileInfo.Ownership. This walks the history once per file, which can be slow for long histories.
+	LineStats           bool              // count the lines each contributor added and removed, and the changed lines in FileInfo.Churn. This diffs every changed file in the history.
+	MergeIdentities     bool              // merge contributors with the same email address, the same name in a different case or the same GitHub login, in addition to applying .mailmap
+	BotPatterns         []string          // regular expressions for the names and email addresses of bots, in addition to DefaultBotPatterns
+	NoDefaultBots       bool              // do not use DefaultBotPatterns
+	ExcludeBots         bool              // leave bots out of ProjectInfo.Contributors. They are still in ContributorList, marked as bots.
+	ChurnSince          time.Time         // only count the commits after this time for FileInfo.Churn and the hotspots, or all commits if zero
+	MaxHotspots         int               // the number of hotspots to list, or 0 for DefaultMaxHotspots
+	LastModifiedFromGit bool              // use the time of the last commit that changed each file for FileInfo.LastModified, instead of the modification time in the file system
+	SubProjects         SubProjectMode    // whether to skip, recurse into or inline git submodules and nested repositories
+	ExcludeClasses      FileClass         // skip files that are in any of these classes, like ClassGenerated | ClassVendored
+	DownRankClasses     FileClass         // list files in any of these classes last, so that they end up in the last chunks, and leave them out of the project type and the hotspots
+	NoAPIServerCheck    bool              // do not check if the project looks like an API server
+	MaxFileSize         int64             // skip files that are larger than this number of bytes, or 0 for no limit
+	Tokenizer           Tokenizer         // the tokenizer for token counts and chunk budgets, or nil to use the one set with SetTokenizer
+	Languages           *LanguageRegistry // the languages to recognize files by, or nil to use DefaultLanguages
+	MaxTokensPerChunk   int               // the token budget for each chunk, or 0 to use the one set with SetMaxTokensPerChunk
+	Workers             int               // the number of files to read and analyze concurrently, or 0 to use one worker per CPU
+	Logger              *log.Logger       // where to log warnings, or nil to not log anything
+	Verbose             bool              // also log every visited path, and call a project without a known name "Untitled"
+}
+
+// logf logs a message with the configured logger, if there is one
+func (opts *Options) logf(format string, v ...interface{}) {
+	if opts.Logger != nil {
+		opts.Logger.Printf(format, v...)
+	}
+}
+
+// workers returns the number of files to read and analyze concurrently
+func (opts *Options) workers() int {
+	if opts.Workers > 0 {
+		return opts.Workers
+	}
+	return runtime.NumCPU()
+}
+
+// tokenizer returns the tokenizer to use for token counts
+func (opts *Options) tokenizer() Tokenizer {
+	if opts.Tokenizer != nil {
+		return opts.Tokenizer
+	}
+	return tokenizer
+}
+
+// languages returns the language registry to recognize files with
+func (opts *Options) languages() *LanguageRegistry {
+	if opts.Languages != nil {
+		return opts.Languages
+	}
+	return DefaultLanguages
+}
+
+// includeMatcher checks if files should be collected, based on the Include patterns
+type includeMatcher struct {
+	paths []*regexp.Regexp // patterns with a slash, matched against the path relative to the project directory
+	names []*regexp.Regexp // patterns without a slash, matched against the file name
+}
+
+// newIncludeMatcher compiles the given glob patterns
+func newIncludeMatcher(patterns []string) (*includeMatcher, error) {
+	m := &includeMatcher{}
+	for _, pattern := range patterns {
+		pattern = strings.TrimPrefix(pattern, "/")
+		re, err := regexp.Compile("^" + globToRegexp(pattern) + "$")
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(pattern, "/") {
+			m.paths = append(m.paths, re)
+		} else {
+			m.names = append(m.names, re)
+		}
+	}
+	return m, nil
+}
+
+// Included checks if the file at the given path, relative to the project directory, should be collected
+func (m *includeMatcher) Included(relPath string) bool {
+	if m == nil || (len(m.paths) == 0 && len(m.names) == 0) {
+		return true
+	}
+	relPath = filepath.ToSlash(relPath)
+	name := path.Base(relPath)
+	for _, re := range m.names {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	for _, re := range m.paths {
+		if re.MatchString(relPath) {
+			return true
+		}
+	}
+	return false
+}
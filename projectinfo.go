@@ -1,68 +1,169 @@
 package projectinfo
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
-	"path/filepath"
 	"strings"
 )
 
 // ProjectInfo holds information about the entire project, useful for generating documentation or other reports.
 type ProjectInfo struct {
-	Name            string     `json:"name"`
-	RepoURL         string     `json:"repositoryURL"`
-	SourceFiles     []FileInfo `json:"sourceFiles"`
-	ConfAndDocFiles []FileInfo `json:"confAndDocFiles"`
-	Type            string     `json:"type"`
-	Contributors    string     `json:"contributors"`
-	APIServer       bool       `json:"apiServer"`
+	Name            string        `json:"name"`
+	RepoURL         string        `json:"repositoryURL"`
+	SourceFiles     []FileInfo    `json:"sourceFiles"`
+	ConfAndDocFiles []FileInfo    `json:"confAndDocFiles"`
+	Type            string        `json:"type"`
+	Contributors    string        `json:"contributors"` // the names of the contributors, separated by ", "
+	ContributorList []Contributor `json:"contributorList"`
+	APIServer       bool          `json:"apiServer"`
+	Tokens          TokenStats    `json:"tokens"`
+	Repository      *RepoURLInfo  `json:"repository,omitempty"`
+	Remotes         []GitRemote   `json:"remotes"`
+	Branch          string        `json:"branch,omitempty"`
+	HeadCommit      string        `json:"headCommit,omitempty"`
+	IgnoreFiles     []IgnoreFile  `json:"ignoreFiles"`
+	Hotspots        []Hotspot     `json:"hotspots"`
+	SubProjects     []SubProject  `json:"subProjects,omitempty"`
+	Warnings        []string      `json:"warnings,omitempty"` // what could not be read, so that some of the information may be missing
+
+	tokenizer         Tokenizer         // used by Chunk, if set
+	maxTokensPerChunk int               // used by Chunk, if set
+	languages         *LanguageRegistry // used by Chunk for splitting files, if set
 }
 
+// New gathers information about the project in the given directory, with the default options.
+// If verbose is true, warnings and visited paths are logged with the standard logger.
 func New(dir string, verbose bool) (ProjectInfo, error) {
+	var opts Options
+	if verbose {
+		opts.Logger = log.Default()
+		opts.Verbose = true
+	}
+	return NewWithOptions(dir, opts)
+}
+
+// NewWithOptions gathers information about the project in the given directory, as configured by the options
+func NewWithOptions(dir string, opts Options) (ProjectInfo, error) {
+	return NewWithContext(context.Background(), dir, opts)
+}
+
+// NewWithContext gathers information about the project in the given directory, as configured by the options.
+// If the context is canceled, the files that are being analyzed are finished, and the context error is returned.
+// Problems that only leave out some of the information, like an unreadable .gitignore file, are listed in ProjectInfo.Warnings.
+func NewWithContext(ctx context.Context, dir string, opts Options) (ProjectInfo, error) {
+	var warnings []string
+	warn := func(format string, v ...interface{}) {
+		warnings = append(warnings, strings.TrimSuffix(fmt.Sprintf(format, v...), "\n"))
+		opts.logf(format, v...)
+	}
+
 	projectName, err := ReadProjectName(dir)
-	if err != nil && verbose {
+	if err != nil && opts.Verbose {
 		projectName = "Untitled"
-		log.Printf("could not find project name, using %q: %v\n", projectName, err)
+		opts.logf("could not find project name, using %q: %v\n", projectName, err)
 	}
 
-	repoURL, err := URLFromGitConfig(filepath.Join(dir, ".git", "config"))
-	if err != nil && verbose {
-		log.Printf("could not find git url from git config: %v\n", err)
+	gitInfo, err := ReadGitRepoInfo(dir)
+	if err != nil && !errors.Is(err, errNotGitRepository) {
+		warn("could not read the git configuration: %v\n", err)
+	}
+	var repoURL string
+	if len(gitInfo.Remotes) > 0 && len(gitInfo.Remotes[0].URLs) > 0 {
+		repoURL = gitInfo.Remotes[0].URLs[0] // "origin" comes first
+	} else if err == nil {
+		opts.logf("could not find git url from git config\n")
 	}
 
-	ignores, err := LoadIgnorePatterns(dir, ".ignore", ".gitignore")
-	if err != nil && verbose {
-		log.Printf("could not read .ignore and/or .gitignore: %v\n", err)
+	bots, err := NewBotDetector(opts.BotPatterns, !opts.NoDefaultBots)
+	if err != nil {
+		return ProjectInfo{}, err
 	}
 
-	var alsoDocAndConf bool
-	const alsoGitContributors = true
-	sourceFiles, err := CollectFiles(dir, ignores, alsoDocAndConf, alsoGitContributors, verbose)
-	if err != nil && verbose {
-		log.Printf("could not collect source files: %v\n", err)
+	ignores, err := LoadProjectIgnores(dir, opts.Ignore)
+	if ignores == nil {
+		return ProjectInfo{}, err
+	}
+	if err != nil {
+		warn("could not read .ignore and/or .gitignore: %v\n", err)
 	}
+	ignores.AddExcludes(dir, opts.Exclude...)
 
-	alsoDocAndConf = true
-	confAndDocFiles, err := CollectFiles(dir, ignores, alsoDocAndConf, alsoGitContributors, verbose)
-	if err != nil && verbose {
-		log.Printf("could not collect documentation and config files: %v\n", err)
+	histories := newGitHistories()
+	histories.mergeIdentities = opts.MergeIdentities
+	defer histories.Close()
+	sourceFiles, confAndDocFiles, subDirs, err := collectFiles(ctx, dir, ignores, histories, &opts)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ProjectInfo{}, ctxErr
+	}
+	if err != nil {
+		warn("could not collect files: %v\n", err)
+	}
+	subProjects, err := readSubProjects(ctx, dir, subDirs, readGitModules(dir), opts)
+	if err != nil {
+		return ProjectInfo{}, err
 	}
 
-	contributors, err := GitContributors(dir)
-	if err != nil && verbose {
-		log.Printf("could not collect contributor names from git: %v\n", err)
+	var contributors []Contributor
+	if !opts.NoGit {
+		contributors, err = gitContributors(histories, dir, opts.LineStats)
+		if err != nil && !errors.Is(err, errNotGitRepository) {
+			warn("could not collect contributor names from git: %v\n", err)
+		}
+		markBots(contributors, bots)
+	}
+	summary := contributors
+	if opts.ExcludeBots {
+		summary = humanContributors(contributors)
 	}
 
-	apiServer := PossiblyAPIServer(dir)
+	var repository *RepoURLInfo
+	if repoURL != "" {
+		if info, err := ParseRepoURL(repoURL); err == nil {
+			repository = &info
+		} else {
+			warn("could not make a web URL from %s: %v\n", repoURL, err)
+		}
+	}
+	if !opts.NoGit { // without the git history, it is not known which files are committed
+		roots := permalinkRoots(dir, gitInfo, repository, subProjects)
+		addPermalinks(sourceFiles, roots)
+		addPermalinks(confAndDocFiles, roots)
+	}
 
-	return ProjectInfo{
-		Name:            projectName,
-		RepoURL:         repoURL,
-		SourceFiles:     sourceFiles,
-		ConfAndDocFiles: confAndDocFiles,
-		Type:            DetectProjectType(sourceFiles),
-		Contributors:    strings.Join(contributors, ", "),
-		APIServer:       apiServer,
-	}, nil
+	var apiServer bool
+	if !opts.NoAPIServerCheck {
+		apiServer = PossiblyAPIServer(dir)
+	}
+
+	project := ProjectInfo{
+		Name:              projectName,
+		RepoURL:           repoURL,
+		Repository:        repository,
+		Remotes:           gitInfo.Remotes,
+		Branch:            gitInfo.Branch,
+		HeadCommit:        gitInfo.HeadCommit,
+		SourceFiles:       sourceFiles,
+		ConfAndDocFiles:   confAndDocFiles,
+		Type:              DetectProjectType(rankedFiles(sourceFiles, opts.DownRankClasses)),
+		Contributors:      strings.Join(contributorNames(summary), ", "),
+		ContributorList:   contributors,
+		APIServer:         apiServer,
+		Tokens:            SumTokens(sourceFiles, confAndDocFiles),
+		IgnoreFiles:       ignores.Files(),
+		SubProjects:       subProjects,
+		Warnings:          warnings,
+		tokenizer:         opts.Tokenizer,
+		maxTokensPerChunk: opts.MaxTokensPerChunk,
+		languages:         opts.Languages,
+	}
+	maxHotspots := opts.MaxHotspots
+	if maxHotspots == 0 {
+		maxHotspots = DefaultMaxHotspots
+	}
+	project.Hotspots = Hotspots(rankedFiles(project.AllFiles(), opts.DownRankClasses), maxHotspots)
+	return project, nil
 }
 
 func (project *ProjectInfo) AllFiles() []FileInfo {
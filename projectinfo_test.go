@@ -0,0 +1,266 @@
+package projectinfo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewTokenCounts(t *testing.T) {
+	tempDir := t.TempDir()
+	if err := setupMockFile(tempDir, "main.go", "package main\n\nfunc main() {}\n"); err != nil {
+		t.Fatalf("Failed to setup mock file: %v", err)
+	}
+	if err := setupMockFile(tempDir, "README.md", "# Example\n\nAn example project.\n"); err != nil {
+		t.Fatalf("Failed to setup mock file: %v", err)
+	}
+
+	project, err := New(tempDir, false)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if len(project.SourceFiles) != 1 || len(project.ConfAndDocFiles) != 1 {
+		t.Fatalf("New() found %d source and %d doc files, want 1 and 1", len(project.SourceFiles), len(project.ConfAndDocFiles))
+	}
+	for _, file := range project.AllFiles() {
+		if want := CountTokens(file.Contents); file.TokenCount != want {
+			t.Errorf("%s has a token count of %d, want %d", file.Path, file.TokenCount, want)
+		}
+	}
+	tokens := project.Tokens
+	if tokens.SourceFiles != project.SourceFiles[0].TokenCount || tokens.ConfAndDocFiles != project.ConfAndDocFiles[0].TokenCount {
+		t.Errorf("wrong token counts per file group: %+v", tokens)
+	}
+	if tokens.Total != tokens.SourceFiles+tokens.ConfAndDocFiles {
+		t.Errorf("the total token count is %d, want %d", tokens.Total, tokens.SourceFiles+tokens.ConfAndDocFiles)
+	}
+	if tokens.PerLanguage["Go"] != tokens.SourceFiles || tokens.PerLanguage["Markdown"] != tokens.ConfAndDocFiles {
+		t.Errorf("wrong token counts per language: %v", tokens.PerLanguage)
+	}
+}
+
+func TestNewIgnoreFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	mockFiles := map[string]string{
+		".ignore":    "secret.go\n",
+		".gitignore": "# generated code\ngen.go\n*.tmp.go\n",
+		"main.go":    "package main\n",
+		"secret.go":  "package main\n",
+		"gen.go":     "package main\n",
+	}
+	for filename, content := range mockFiles {
+		if err := setupMockFile(tempDir, filename, content); err != nil {
+			t.Fatalf("Failed to setup mock file: %v", err)
+		}
+	}
+
+	project, err := New(tempDir, false)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if len(project.SourceFiles) != 1 || filepath.Base(project.SourceFiles[0].Path) != "main.go" {
+		t.Errorf("New() collected %v, want only main.go", project.SourceFiles)
+	}
+	wantPatterns := map[string]int{".gitignore": 2, ".ignore": 1}
+	for _, ignoreFile := range project.IgnoreFiles {
+		if filepath.Dir(ignoreFile.Path) != tempDir {
+			continue // global excludes
+		}
+		name := filepath.Base(ignoreFile.Path)
+		if ignoreFile.Patterns != wantPatterns[name] {
+			t.Errorf("%s contributed %d patterns, want %d", name, ignoreFile.Patterns, wantPatterns[name])
+		}
+		delete(wantPatterns, name)
+	}
+	if len(wantPatterns) > 0 {
+		t.Errorf("these ignore files were not reported: %v", wantPatterns)
+	}
+}
+
+func TestNewWithOptions(t *testing.T) {
+	tempDir := t.TempDir()
+	mockFiles := map[string]string{
+		"main.go":      "package main\n\nfunc main() {}\n",
+		"main_test.go": "package main\n",
+		"big.go":       "package main\n\n// " + strings.Repeat("x", 1000) + "\n",
+		"script.py":    "print('hi')\n",
+		"README.md":    "# Example\n",
+	}
+	for filename, content := range mockFiles {
+		if err := setupMockFile(tempDir, filename, content); err != nil {
+			t.Fatalf("Failed to setup mock file: %v", err)
+		}
+	}
+
+	project, err := NewWithOptions(tempDir, Options{
+		Include:           []string{"*.go", "*.md"},
+		Exclude:           []string{"*_test.go"},
+		NoContents:        true,
+		NoGit:             true,
+		MaxFileSize:       500,
+		Tokenizer:         NewBPETokenizer(map[string]int{}),
+		MaxTokensPerChunk: 1000,
+	})
+	if err != nil {
+		t.Fatalf("NewWithOptions() error = %v", err)
+	}
+	if len(project.SourceFiles) != 1 || filepath.Base(project.SourceFiles[0].Path) != "main.go" {
+		t.Fatalf("NewWithOptions() collected %v, want only main.go", project.SourceFiles)
+	}
+	main := project.SourceFiles[0]
+	if main.Contents != "" {
+		t.Errorf("main.go has contents %q, want none", main.Contents)
+	}
+	// With an empty BPE vocabulary, every byte is a token
+	if main.TokenCount != len(mockFiles["main.go"]) || main.LineCount != 3 {
+		t.Errorf("main.go has %d tokens and %d lines, want %d and 3", main.TokenCount, main.LineCount, len(mockFiles["main.go"]))
+	}
+	if len(project.ConfAndDocFiles) != 1 {
+		t.Errorf("NewWithOptions() collected %d doc files, want 1", len(project.ConfAndDocFiles))
+	}
+
+	chunks, err := project.Chunk(true, true)
+	if err != nil {
+		t.Fatalf("Chunk() error = %v", err)
+	}
+	for i, chunk := range chunks {
+		if len(chunk) > 1000 {
+			t.Errorf("chunk %d has %d bytes, which is more than the token budget of 1000", i+1, len(chunk))
+		}
+	}
+
+	if _, err := NewWithOptions(tempDir, Options{Ignore: IgnoreOptions{Presets: []string{"unknown"}}}); err == nil {
+		t.Error("NewWithOptions() with an unknown ignore preset should fail")
+	}
+}
+
+func BenchmarkNew(b *testing.B) {
+	tempDir := b.TempDir()
+	for i := 0; i < 50; i++ {
+		dir := filepath.Join(tempDir, fmt.Sprintf("pkg%d", i))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			b.Fatalf("Failed to create directory: %v", err)
+		}
+		for j := 0; j < 10; j++ {
+			if err := setupMockFile(dir, fmt.Sprintf("file%d.go", j), "package pkg\n\nfunc f() {}\n"); err != nil {
+				b.Fatalf("Failed to setup mock file: %v", err)
+			}
+		}
+		if err := setupMockFile(dir, "README.md", "# pkg\n"); err != nil {
+			b.Fatalf("Failed to setup mock file: %v", err)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewWithOptions(tempDir, Options{NoGit: true}); err != nil {
+			b.Fatalf("NewWithOptions() error = %v", err)
+		}
+	}
+}
+
+func TestNewWithContext(t *testing.T) {
+	tempDir := t.TempDir()
+	for i := 0; i < 30; i++ {
+		if err := setupMockFile(tempDir, fmt.Sprintf("file%02d.go", i), strings.Repeat("// line\n", i)); err != nil {
+			t.Fatalf("Failed to setup mock file: %v", err)
+		}
+	}
+
+	serial, err := NewWithContext(context.Background(), tempDir, Options{NoGit: true, Workers: 1})
+	if err != nil {
+		t.Fatalf("NewWithContext() error = %v", err)
+	}
+	concurrent, err := NewWithContext(context.Background(), tempDir, Options{NoGit: true, Workers: 8})
+	if err != nil {
+		t.Fatalf("NewWithContext() error = %v", err)
+	}
+	if len(serial.SourceFiles) != 30 || len(concurrent.SourceFiles) != 30 {
+		t.Fatalf("got %d and %d source files, want 30", len(serial.SourceFiles), len(concurrent.SourceFiles))
+	}
+	for i := range serial.SourceFiles {
+		if serial.SourceFiles[i].Path != concurrent.SourceFiles[i].Path || serial.SourceFiles[i].LineCount != concurrent.SourceFiles[i].LineCount {
+			t.Errorf("file %d differs: %s and %s", i, serial.SourceFiles[i].Path, concurrent.SourceFiles[i].Path)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewWithContext(ctx, tempDir, Options{NoGit: true}); !errors.Is(err, context.Canceled) {
+		t.Errorf("NewWithContext() with a canceled context returned %v, want %v", err, context.Canceled)
+	}
+
+	// Only verbose callers get a placeholder name for a project without a known name
+	if serial.Name != "" {
+		t.Errorf("Name = %q, want an empty name", serial.Name)
+	}
+	verbose, err := NewWithContext(context.Background(), tempDir, Options{NoGit: true, Verbose: true})
+	if err != nil {
+		t.Fatalf("NewWithContext() error = %v", err)
+	}
+	if verbose.Name != "Untitled" {
+		t.Errorf("Name with Verbose = %q, want %q", verbose.Name, "Untitled")
+	}
+}
+
+func TestLastModifiedFromGit(t *testing.T) {
+	repo := newTestGitRepo(t)
+	c1 := repo.commit(repo.tree(map[string]gitHash{"a.go": repo.blob("package a\n")}), "Alice", 1000)
+	c2 := repo.commit(repo.tree(map[string]gitHash{"a.go": repo.blob("package a // 2\n")}), "Bob", 3000, c1)
+	repo.setRef("refs/heads/main", c2)
+	repo.writeFile("a.go", "package a // 2\n")
+	repo.writeFile("b.go", "package a // not committed\n")
+
+	for _, fromGit := range []bool{false, true} {
+		project, err := NewWithOptions(repo.dir, Options{LastModifiedFromGit: fromGit, NoAPIServerCheck: true})
+		if err != nil {
+			t.Fatalf("NewWithOptions() error = %v", err)
+		}
+		a := FindFileName(project.SourceFiles, "a.go")
+		if a.LastCommit != c2.String() || a.LastAuthor != "Bob" {
+			t.Errorf("last commit of a.go = %s by %s, want %s by Bob", a.LastCommit, a.LastAuthor, c2)
+		}
+		if _, err := time.Parse(time.RFC3339, a.ModTime); err != nil {
+			t.Errorf("ModTime %q is not RFC 3339: %v", a.ModTime, err)
+		}
+		wantLastModified := a.ModTime
+		if fromGit {
+			wantLastModified = "1970-01-01T00:50:00Z"
+		}
+		if a.LastModified != wantLastModified {
+			t.Errorf("LastModified of a.go with LastModifiedFromGit = %v is %q, want %q", fromGit, a.LastModified, wantLastModified)
+		}
+		b := FindFileName(project.SourceFiles, "b.go")
+		if b.LastCommit != "" || b.LastModified != b.ModTime {
+			t.Errorf("uncommitted b.go has last commit %q and LastModified %q, want none and %q", b.LastCommit, b.LastModified, b.ModTime)
+		}
+	}
+}
+
+func TestNewWarnings(t *testing.T) {
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	// A directory that is not in a git repository is not a problem
+	project, err := NewWithOptions(t.TempDir(), Options{NoAPIServerCheck: true})
+	if err != nil {
+		t.Fatalf("NewWithOptions() error = %v", err)
+	}
+	if len(project.Warnings) != 0 {
+		t.Errorf("Warnings outside of a git repository = %q, want none", project.Warnings)
+	}
+
+	// A remote that has no web URL is reported, even without a logger
+	repo := buildTestHistory(t)
+	repo.writeFile(".git/config", "[remote \"origin\"]\n\turl = /srv/git/repo.git\n")
+	project, err = NewWithOptions(repo.dir, Options{NoAPIServerCheck: true})
+	if err != nil {
+		t.Fatalf("NewWithOptions() error = %v", err)
+	}
+	if len(project.Warnings) != 1 || !strings.Contains(project.Warnings[0], "/srv/git/repo.git") {
+		t.Errorf("Warnings = %q, want one about the remote URL", project.Warnings)
+	}
+}
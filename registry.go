@@ -0,0 +1,253 @@
+package projectinfo
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CategoryData is the category of data files, like XML. They are collected with the documentation and configuration files.
+const CategoryData = "data"
+
+// Language describes a programming, markup or data language, and how files in that language can be recognized
+type Language struct {
+	Name                  string    `json:"name"`
+	Category              string    `json:"category"`                        // one of CategorySource, CategoryDocumentation, CategoryConfiguration, CategoryBuild and CategoryData
+	Extensions            []string  `json:"extensions,omitempty"`            // like ".go" or ".d.ts", matched case-insensitively
+	Filenames             []string  `json:"filenames,omitempty"`             // whole file names that identify the language, like "makefile", in lowercase
+	Shebangs              []string  `json:"shebangs,omitempty"`              // interpreters in "#!" lines, like "python3"
+	LineComment           string    `json:"lineComment,omitempty"`           // like "//"
+	BlockComment          [2]string `json:"blockComment,omitempty"`          // the start and end of a block comment, like "/*" and "*/"
+	SignificantWhitespace bool      `json:"significantWhitespace,omitempty"` // leading whitespace matters, so OptimizeCode keeps it
+	Declaration           string    `json:"declaration,omitempty"`           // a regular expression for the first line of a function, class or other declaration, where SplitFile prefers to split
+	Annotations           []string  `json:"annotations,omitempty"`           // prefixes of lines that, like comments, belong to the declaration that follows, like "@"
+	Aliases               []string  `json:"aliases,omitempty"`               // other names for the language, like "golang"
+
+	declaration *regexp.Regexp // the compiled Declaration
+}
+
+//go:embed languages.json
+var embeddedLanguages []byte
+
+// LanguageRegistry holds the known languages, and looks them up by name, alias, extension, filename or shebang interpreter.
+// It is safe for concurrent use.
+type LanguageRegistry struct {
+	mu            sync.RWMutex
+	languages     []Language
+	byName        map[string]int // lowercase names and aliases
+	byExtension   map[string]int
+	byFilename    map[string]int
+	byInterpreter map[string]int
+}
+
+// DefaultLanguages is the registry that is used by the package level functions, like LanguageFromExtension and FileCategory.
+// It starts out with the built-in languages, and can be extended with RegisterLanguage or LoadLanguages.
+var DefaultLanguages = mustLanguageRegistry()
+
+// mustLanguageRegistry is like NewLanguageRegistry, but panics if the built-in languages can not be read
+func mustLanguageRegistry() *LanguageRegistry {
+	registry, err := NewLanguageRegistry()
+	if err != nil {
+		panic(err)
+	}
+	return registry
+}
+
+// NewLanguageRegistry returns a registry with the built-in languages
+func NewLanguageRegistry() (*LanguageRegistry, error) {
+	registry := &LanguageRegistry{}
+	registry.index()
+	if err := registry.Load(strings.NewReader(string(embeddedLanguages))); err != nil {
+		return nil, fmt.Errorf("built-in languages: %v", err)
+	}
+	return registry, nil
+}
+
+// validCategory checks if the given category can be used for a language
+func validCategory(category string) bool {
+	switch category {
+	case CategorySource, CategoryDocumentation, CategoryConfiguration, CategoryBuild, CategoryData:
+		return true
+	}
+	return false
+}
+
+// Add adds a language to the registry. A language with the same name replaces the existing one.
+// The extensions, filenames and shebangs of the new language take precedence over those of the languages that were added before it.
+func (registry *LanguageRegistry) Add(language Language) error {
+	if language.Name == "" {
+		return fmt.Errorf("a language needs a name")
+	}
+	if !validCategory(language.Category) {
+		return fmt.Errorf("invalid category %q for the language %s", language.Category, language.Name)
+	}
+	extensions := make([]string, len(language.Extensions))
+	for i, ext := range language.Extensions {
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		extensions[i] = strings.ToLower(ext)
+	}
+	language.Extensions = extensions
+	filenames := make([]string, len(language.Filenames))
+	for i, filename := range language.Filenames {
+		filenames[i] = strings.ToLower(filename)
+	}
+	language.Filenames = filenames
+	language.declaration = nil
+	if language.Declaration != "" {
+		re, err := regexp.Compile(language.Declaration)
+		if err != nil {
+			return fmt.Errorf("invalid declaration pattern for the language %s: %v", language.Name, err)
+		}
+		language.declaration = re
+	}
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	for i, existing := range registry.languages {
+		if strings.EqualFold(existing.Name, language.Name) {
+			registry.languages = append(registry.languages[:i], registry.languages[i+1:]...)
+			break
+		}
+	}
+	registry.languages = append(registry.languages, language)
+	registry.index()
+	return nil
+}
+
+// index rebuilds the lookup tables, letting later languages take precedence over earlier ones
+func (registry *LanguageRegistry) index() {
+	registry.byName = make(map[string]int)
+	registry.byExtension = make(map[string]int)
+	registry.byFilename = make(map[string]int)
+	registry.byInterpreter = make(map[string]int)
+	for i, language := range registry.languages {
+		for _, alias := range language.Aliases {
+			registry.byName[strings.ToLower(alias)] = i
+		}
+		for _, ext := range language.Extensions {
+			registry.byExtension[ext] = i
+		}
+		for _, filename := range language.Filenames {
+			registry.byFilename[filename] = i
+		}
+		for _, interpreter := range language.Shebangs {
+			registry.byInterpreter[interpreter] = i
+		}
+	}
+	// Names take precedence over aliases
+	for i, language := range registry.languages {
+		registry.byName[strings.ToLower(language.Name)] = i
+	}
+}
+
+// Load adds the languages in a JSON array, in the same format as the built-in languages, to the registry
+func (registry *LanguageRegistry) Load(r io.Reader) error {
+	var languages []Language
+	if err := json.NewDecoder(r).Decode(&languages); err != nil {
+		return err
+	}
+	for _, language := range languages {
+		if err := registry.Add(language); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadFile adds the languages in a JSON file to the registry
+func (registry *LanguageRegistry) LoadFile(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := registry.Load(f); err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+	return nil
+}
+
+// lookup returns the language at the index that the given table has for the given key
+func (registry *LanguageRegistry) lookup(table map[string]int, key string) (Language, bool) {
+	if i, ok := table[key]; ok {
+		return registry.languages[i], true
+	}
+	return Language{}, false
+}
+
+// Lookup finds a language by its name or one of its aliases, case-insensitively
+func (registry *LanguageRegistry) Lookup(name string) (Language, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	return registry.lookup(registry.byName, strings.ToLower(name))
+}
+
+// lookupExtension finds the language of the longest extension of a file name that is in the registry,
+// like ".d.ts" before ".ts", ignoring the case
+func (registry *LanguageRegistry) lookupExtension(name string) (Language, bool) {
+	name = strings.ToLower(name)
+	for i := 0; i < len(name); i++ {
+		if name[i] != '.' {
+			continue
+		}
+		if language, ok := registry.lookup(registry.byExtension, name[i:]); ok {
+			return language, true
+		}
+	}
+	return Language{}, false
+}
+
+// ForExtension finds the language of a file extension, like ".go", ".GO" or ".d.ts"
+func (registry *LanguageRegistry) ForExtension(ext string) (Language, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	return registry.lookupExtension(ext)
+}
+
+// ForInterpreter finds the language of an interpreter in a "#!" line, like "python3"
+func (registry *LanguageRegistry) ForInterpreter(interpreter string) (Language, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	return registry.lookup(registry.byInterpreter, filepath.Base(interpreter))
+}
+
+// ForPath finds the language of a file by its name, like "Makefile", or else by its longest known extension.
+// Both are matched case-insensitively.
+func (registry *LanguageRegistry) ForPath(path string) (Language, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	name := filepath.Base(path)
+	if language, ok := registry.lookup(registry.byFilename, strings.ToLower(name)); ok {
+		return language, true
+	}
+	return registry.lookupExtension(name)
+}
+
+// Languages returns all languages in the registry, sorted by name
+func (registry *LanguageRegistry) Languages() []Language {
+	registry.mu.RLock()
+	languages := append([]Language{}, registry.languages...)
+	registry.mu.RUnlock()
+	sort.Slice(languages, func(i, j int) bool {
+		return strings.ToLower(languages[i].Name) < strings.ToLower(languages[j].Name)
+	})
+	return languages
+}
+
+// RegisterLanguage adds a language to DefaultLanguages
+func RegisterLanguage(language Language) error {
+	return DefaultLanguages.Add(language)
+}
+
+// LoadLanguages adds the languages in a JSON file to DefaultLanguages
+func LoadLanguages(filename string) error {
+	return DefaultLanguages.LoadFile(filename)
+}
@@ -0,0 +1,240 @@
+package projectinfo
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Kinds of code hosting services, which decide how web URLs and permalinks look
+const (
+	HostGitHub    = "GitHub"
+	HostGitLab    = "GitLab"
+	HostBitbucket = "Bitbucket"
+	HostGitea     = "Gitea"
+	HostSourcehut = "sourcehut"
+)
+
+// RepoURLInfo describes where a repository can be browsed on the web
+type RepoURLInfo struct {
+	WebURL string `json:"webURL"` // like https://github.com/xyproto/projectinfo
+	Host   string `json:"host"`   // the host name, and the port if it is not the default one
+	Kind   string `json:"kind,omitempty"`
+	Owner  string `json:"owner,omitempty"` // the user, organization or group, which may contain slashes for GitLab subgroups
+	Repo   string `json:"repo"`
+}
+
+// ParseRepoURL converts a remote URL, in any of the forms git accepts, to a canonical https web URL.
+// Supported forms include git@host:owner/repo.git, ssh://git@host:22/owner/repo.git, git://host/owner/repo and https://user@host/owner/repo.git.
+func ParseRepoURL(remote string) (RepoURLInfo, error) {
+	remote = strings.TrimSpace(remote)
+	var host, repoPath string
+	if scheme, _, found := strings.Cut(remote, "://"); found {
+		u, err := url.Parse(remote)
+		if err != nil {
+			return RepoURLInfo{}, err
+		}
+		switch strings.ToLower(scheme) {
+		case "https", "http":
+			host = u.Host // a custom port is kept, since the web server is likely on it too
+		case "ssh", "git", "git+ssh", "ssh+git":
+			host = u.Hostname() // the port is for ssh or git, not for the web server
+		default:
+			return RepoURLInfo{}, fmt.Errorf("unsupported URL scheme in %q", remote)
+		}
+		if host == "" {
+			return RepoURLInfo{}, fmt.Errorf("no host in %q", remote)
+		}
+		repoPath = u.Path
+	} else {
+		// The scp-like form: [user@]host:path, where the colon comes before any slash
+		colon := strings.Index(remote, ":")
+		if colon <= 1 || strings.Contains(remote[:colon], "/") { // a single letter is a Windows drive
+			return RepoURLInfo{}, fmt.Errorf("%q is not a remote URL", remote)
+		}
+		host = remote[:colon]
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+		repoPath = remote[colon+1:]
+	}
+	host = strings.ToLower(host)
+	repoPath = strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git")
+	repoPath = strings.TrimSuffix(repoPath, "/")
+	if repoPath == "" {
+		return RepoURLInfo{}, errors.New("no repository path in " + remote)
+	}
+
+	info := RepoURLInfo{Host: host, Kind: hostKind(host)}
+	info.Owner, info.Repo = path.Split(repoPath)
+	info.Owner = strings.TrimSuffix(info.Owner, "/")
+	info.WebURL = "https://" + host + "/" + repoPath
+	if info.Kind == HostSourcehut {
+		info.Owner = strings.TrimPrefix(info.Owner, "~") // sourcehut user names are written as ~user
+	}
+	return info, nil
+}
+
+// stripCredentials removes passwords and tokens from a remote URL, so that they are never stored or shown.
+// The user info of http and https URLs is removed, since a token may be given as the user name, like in https://token@host/owner/repo.git.
+// For other URLs and the scp-like form, only a password is removed, and the login, like "git" in git@host:owner/repo.git, is kept.
+func stripCredentials(remote string) string {
+	if scheme, rest, found := strings.Cut(remote, "://"); found {
+		web := strings.EqualFold(scheme, "https") || strings.EqualFold(scheme, "http")
+		if u, err := url.Parse(remote); err == nil {
+			if u.User == nil {
+				return remote
+			}
+			if web {
+				u.User = nil
+			} else if _, hasPassword := u.User.Password(); hasPassword {
+				u.User = url.User(u.User.Username())
+			} else {
+				return remote
+			}
+			return u.String()
+		}
+		// Cut the credentials from the authority by hand, if the URL could not be parsed
+		authority, _, _ := strings.Cut(rest, "/")
+		at := strings.LastIndex(authority, "@")
+		if at < 0 {
+			return remote
+		}
+		prefix := remote[:len(remote)-len(rest)]
+		if web {
+			return prefix + rest[at+1:]
+		}
+		if login, _, hasPassword := strings.Cut(authority[:at], ":"); hasPassword {
+			return prefix + login + rest[at:]
+		}
+		return remote
+	}
+	// The scp-like form, [user[:password]@]host:path
+	at := strings.LastIndex(remote, "@")
+	if at <= 0 || strings.Contains(remote[:at], "/") {
+		return remote
+	}
+	if login, _, hasPassword := strings.Cut(remote[:at], ":"); hasPassword {
+		return login + remote[at:]
+	}
+	return remote
+}
+
+// hostKind guesses which code hosting service a host name belongs to
+func hostKind(host string) string {
+	name := strings.Split(host, ":")[0]
+	switch {
+	case name == "github.com" || strings.Contains(name, "github"):
+		return HostGitHub
+	case name == "gitlab.com" || strings.Contains(name, "gitlab"):
+		return HostGitLab
+	case name == "bitbucket.org" || strings.Contains(name, "bitbucket"):
+		return HostBitbucket
+	case name == "codeberg.org" || strings.Contains(name, "gitea") || strings.Contains(name, "forgejo"):
+		return HostGitea
+	case name == "git.sr.ht" || strings.HasSuffix(name, ".sr.ht"):
+		return HostSourcehut
+	}
+	return ""
+}
+
+// Permalink returns a web URL for a file at a given commit, pointing to the given lines if startLine is larger than 0.
+// The path is relative to the root of the repository. Unknown hosts get GitHub style links.
+func (info RepoURLInfo) Permalink(commit, relPath string, startLine, endLine int) string {
+	segments := strings.Split(strings.TrimPrefix(relPath, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	escaped := strings.Join(segments, "/")
+
+	var link, anchor string
+	switch info.Kind {
+	case HostGitLab:
+		link = info.WebURL + "/-/blob/" + commit + "/" + escaped
+		anchor = lineAnchor("#L", "-", startLine, endLine)
+	case HostBitbucket:
+		link = info.WebURL + "/src/" + commit + "/" + escaped
+		anchor = lineAnchor("#lines-", ":", startLine, endLine)
+	case HostGitea:
+		link = info.WebURL + "/src/commit/" + commit + "/" + escaped
+		anchor = lineAnchor("#L", "-L", startLine, endLine)
+	case HostSourcehut:
+		link = info.WebURL + "/tree/" + commit + "/item/" + escaped
+		anchor = lineAnchor("#L", "-", startLine, endLine)
+	default:
+		link = info.WebURL + "/blob/" + commit + "/" + escaped
+		anchor = lineAnchor("#L", "-L", startLine, endLine)
+	}
+	return link + anchor
+}
+
+// lineAnchor formats a line number or a range of lines, like "#L10-L20"
+func lineAnchor(prefix, separator string, startLine, endLine int) string {
+	switch {
+	case startLine <= 0:
+		return ""
+	case endLine <= startLine:
+		return fmt.Sprintf("%s%d", prefix, startLine)
+	}
+	return fmt.Sprintf("%s%d%s%d", prefix, startLine, separator, endLine)
+}
+
+// fileLink is what is needed to make permalinks for a file and for the parts it may be split into
+type fileLink struct {
+	repo    RepoURLInfo
+	commit  string
+	relPath string // relative to the root of the repository
+	lines   bool   // the file is unchanged since the commit, so that links can point to lines
+}
+
+// permalink returns a link to the file, or to the given lines of it if startLine is larger than 0 and the file is unchanged since the commit
+func (link *fileLink) permalink(startLine, endLine int) string {
+	if link == nil {
+		return ""
+	}
+	if !link.lines {
+		startLine, endLine = 0, 0
+	}
+	return link.repo.Permalink(link.commit, link.relPath, startLine, endLine)
+}
+
+// permalinkRoot is a repository that files can be linked to, like the project itself or one of its sub-projects
+type permalinkRoot struct {
+	dir    string       // the absolute path of the work tree
+	repo   *RepoURLInfo // nil if the repository has no web URL
+	commit string
+}
+
+// addPermalinks sets the permalinks of the committed files, for the commit of the innermost root that contains each file.
+// Files in a root without a web URL or a commit get no permalink, since there is nothing to link to.
+// Only files that are unchanged since the commit get links to lines when they are split, since the line numbers may be wrong otherwise.
+func addPermalinks(files []FileInfo, roots []permalinkRoot) {
+	for i := range files {
+		if files[i].LastCommit == "" {
+			continue
+		}
+		abs, err := filepath.Abs(files[i].Path)
+		if err != nil {
+			continue
+		}
+		var root *permalinkRoot
+		var relPath string
+		for j := range roots {
+			rel, err := filepath.Rel(roots[j].dir, abs)
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				continue
+			}
+			if root == nil || len(roots[j].dir) > len(root.dir) {
+				root, relPath = &roots[j], rel
+			}
+		}
+		if root == nil || root.repo == nil || root.commit == "" {
+			continue
+		}
+		files[i].link = &fileLink{repo: *root.repo, commit: root.commit, relPath: filepath.ToSlash(relPath), lines: files[i].unchanged}
+		files[i].Permalink = files[i].link.permalink(0, 0)
+	}
+}
@@ -0,0 +1,260 @@
+package projectinfo
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Boundary scores, used when deciding where to split a file. A higher score is a better place to split.
+const (
+	splitAnywhere    = 0 // in the middle of a line that is too long to fit in a single part
+	splitLine        = 1 // at the start of any line
+	splitBlankLine   = 2 // right after a blank line
+	splitDeclaration = 3 // at the start of a function, class or other top-level declaration
+)
+
+// splitRule describes how to find good places to split the source code of a language
+type splitRule struct {
+	declaration *regexp.Regexp // matches the first line of a function, class or other declaration
+	leading     []string       // prefixes of lines that belong to the declaration that follows, like comments and annotations
+}
+
+// splitRuleFor derives the rule for splitting files in a language from its registry entry, where comments and annotations are leading lines.
+// It returns nil if the language has neither declarations nor comments.
+func splitRuleFor(language Language) *splitRule {
+	rule := &splitRule{declaration: language.declaration}
+	for _, prefix := range []string{language.LineComment, language.BlockComment[0], language.BlockComment[1]} {
+		if prefix != "" {
+			rule.leading = append(rule.leading, prefix)
+		}
+	}
+	if strings.HasSuffix(language.BlockComment[0], "*") {
+		rule.leading = append(rule.leading, "*") // the continuation lines of block comments, like " * text"
+	}
+	rule.leading = append(rule.leading, language.Annotations...)
+	if rule.declaration == nil && len(rule.leading) == 0 {
+		return nil
+	}
+	return rule
+}
+
+// fileSegment is a line, or a fragment of a long line, of a file that is being split
+type fileSegment struct {
+	text   string
+	line   int // 1-based line number
+	tokens int
+	score  int // how good a place it is to split the file right before this segment
+}
+
+// contentTokens returns the number of tokens the given text needs when it is encoded as a JSON string
+func contentTokens(t Tokenizer, text string) int {
+	s, err := marshalJSON(text)
+	if err != nil {
+		return t.CountTokens(text)
+	}
+	return t.CountTokens(s[1 : len(s)-1])
+}
+
+// isLeadingLine checks if the given line belongs to the declaration that follows it, like a comment or an annotation
+func isLeadingLine(line string, rule *splitRule) bool {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" {
+		return false
+	}
+	for _, prefix := range rule.leading {
+		if strings.HasPrefix(trimmed, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// boundaryScores returns a score for each line, telling how good a place it is to split the file right before that line.
+// Without a declaration pattern, comments that follow a blank line are used as declaration boundaries instead.
+func boundaryScores(lines []string, rule *splitRule) []int {
+	scores := make([]int, len(lines))
+	for i := range lines {
+		scores[i] = splitLine
+		if i > 0 && strings.TrimSpace(lines[i-1]) == "" && strings.TrimSpace(lines[i]) != "" {
+			scores[i] = splitBlankLine
+		}
+	}
+	if rule == nil {
+		return scores
+	}
+	for i, line := range lines {
+		if rule.declaration == nil {
+			if scores[i] == splitBlankLine && isLeadingLine(line, rule) {
+				scores[i] = splitDeclaration
+			}
+			continue
+		}
+		if !rule.declaration.MatchString(line) {
+			continue
+		}
+		// Include any comments and annotations that are directly above the declaration
+		start := i
+		for start > 0 && isLeadingLine(lines[start-1], rule) {
+			start--
+		}
+		scores[start] = splitDeclaration
+	}
+	return scores
+}
+
+// splitLongLine splits a line that needs more than maxTokens tokens into fragments that each need at most maxTokens tokens
+func splitLongLine(t Tokenizer, line string, maxTokens int) []string {
+	var fragments []string
+	for line != "" {
+		// Find the longest prefix that fits, with a binary search on the number of runes
+		runes := utf8.RuneCountInString(line)
+		low, high := 1, runes
+		for low < high {
+			mid := (low + high + 1) / 2
+			if contentTokens(t, runePrefix(line, mid)) <= maxTokens {
+				low = mid
+			} else {
+				high = mid - 1
+			}
+		}
+		fragment := runePrefix(line, low)
+		fragments = append(fragments, fragment)
+		line = line[len(fragment):]
+	}
+	return fragments
+}
+
+// runePrefix returns the first n runes of s
+func runePrefix(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
+
+// segmentFile divides the contents of a file into segments that each need at most maxTokens tokens, using the given rule for finding boundaries, if any
+func segmentFile(t Tokenizer, contents string, rule *splitRule, maxTokens int) []fileSegment {
+	lines := strings.SplitAfter(contents, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	scores := boundaryScores(lines, rule)
+	var segments []fileSegment
+	for i, line := range lines {
+		tokens := contentTokens(t, line)
+		if tokens <= maxTokens {
+			segments = append(segments, fileSegment{text: line, line: i + 1, tokens: tokens, score: scores[i]})
+			continue
+		}
+		for j, fragment := range splitLongLine(t, line, maxTokens) {
+			score := splitAnywhere
+			if j == 0 {
+				score = scores[i]
+			}
+			segments = append(segments, fileSegment{text: fragment, line: i + 1, tokens: contentTokens(t, fragment), score: score})
+		}
+	}
+	return segments
+}
+
+// bestBoundary returns the index of the segment where the part that starts at start should end, given that segments[start:end] is the largest part that fits.
+// Boundaries in the first third of the part are not considered, to avoid producing many tiny parts.
+func bestBoundary(segments []fileSegment, start, end int) int {
+	if end >= len(segments) {
+		return len(segments)
+	}
+	lowest := start + (end-start)/3
+	if lowest <= start {
+		lowest = start + 1
+	}
+	best, bestScore := end, segments[end].score
+	for b := end - 1; b >= lowest; b-- {
+		if segments[b].score > bestScore {
+			best, bestScore = b, segments[b].score
+		}
+	}
+	return best
+}
+
+// SplitFile splits a file into numbered parts, where the JSON representation of each part (plus a separating comma) needs at most maxTokens tokens.
+// The splits are placed at function, class or blank line boundaries whenever possible, and only fall back to splitting at any line, or within a very long line, when nothing better is found.
+func SplitFile(file FileInfo, maxTokens int) ([]FileInfo, error) {
+	return splitFile(tokenizer, DefaultLanguages, file, maxTokens)
+}
+
+// splitFile splits a file into numbered parts, counting tokens with the given tokenizer and finding boundaries with the given language registry
+func splitFile(t Tokenizer, languages *LanguageRegistry, file FileInfo, maxTokens int) ([]FileInfo, error) {
+	// Measure everything except the contents, with large part and line numbers to leave room for the real numbers
+	envelope := file
+	envelope.Contents = ""
+	envelope.Part, envelope.TotalParts = math.MaxInt32, math.MaxInt32
+	envelope.StartLine, envelope.EndLine = math.MaxInt32, math.MaxInt32
+	if file.link != nil {
+		envelope.Permalink = file.link.permalink(math.MaxInt32, math.MaxInt32)
+	}
+	envelopeTokens, err := jsonTokens(t, envelope)
+	if err != nil {
+		return nil, err
+	}
+	envelopeTokens += t.CountTokens(`,"contents":""`) + 1 // +1 for the separating comma
+	budget := maxTokens - envelopeTokens
+	if budget <= 0 {
+		return nil, fmt.Errorf("%s can not be split into parts of %d tokens, since the file information alone needs %d tokens", file.Path, maxTokens, envelopeTokens)
+	}
+
+	var rule *splitRule
+	if language, ok := languages.Lookup(file.Language); ok {
+		rule = splitRuleFor(language)
+	}
+	segments := segmentFile(t, file.Contents, rule, budget)
+	var parts []FileInfo
+	for start := 0; start < len(segments); {
+		end, tokens := start, 0
+		for end < len(segments) && tokens+segments[end].tokens <= budget {
+			tokens += segments[end].tokens
+			end++
+		}
+		if end == start {
+			end = start + 1
+		}
+		end = bestBoundary(segments, start, end)
+
+		// The token estimate is not always additive, so verify the real size of the part and shrink it if needed
+		for {
+			var sb strings.Builder
+			for _, segment := range segments[start:end] {
+				sb.WriteString(segment.text)
+			}
+			part := file
+			part.Contents = sb.String()
+			part.StartLine = segments[start].line
+			part.EndLine = segments[end-1].line
+			if file.link != nil {
+				part.Permalink = file.link.permalink(part.StartLine, part.EndLine)
+			}
+			part.Part, part.TotalParts = math.MaxInt32, math.MaxInt32
+			partTokens, err := jsonTokens(t, part)
+			if err != nil {
+				return nil, err
+			}
+			if partTokens+1 <= maxTokens || end-start == 1 {
+				parts = append(parts, part)
+				break
+			}
+			end--
+		}
+		start = end
+	}
+
+	for i := range parts {
+		parts[i].Part = i + 1
+		parts[i].TotalParts = len(parts)
+	}
+	return parts, nil
+}
@@ -0,0 +1,133 @@
+package projectinfo
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSplitFile(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("package main\n\nimport \"fmt\"\n\n")
+	for i := 0; i < 40; i++ {
+		fmt.Fprintf(&sb, "// f%d prints a number\nfunc f%d() {\n\tfmt.Println(%d)\n\tfmt.Println(\"some more text\")\n}\n\n", i, i, i)
+	}
+	file := FileInfo{Path: "main.go", Language: "Go", Contents: sb.String()}
+
+	const maxTokens = 300
+	parts, err := SplitFile(file, maxTokens)
+	if err != nil {
+		t.Fatalf("SplitFile() error = %v", err)
+	}
+	if len(parts) < 2 {
+		t.Fatalf("SplitFile() returned %d parts, want at least 2", len(parts))
+	}
+
+	var joined strings.Builder
+	nextLine := 1
+	for i, part := range parts {
+		if tokens, _ := jsonTokens(tokenizer, part); tokens+1 > maxTokens {
+			t.Errorf("part %d needs %d tokens, want at most %d", i+1, tokens+1, maxTokens)
+		}
+		if part.Part != i+1 || part.TotalParts != len(parts) || part.Path != file.Path {
+			t.Errorf("part %d is labeled %d of %d for %q", i+1, part.Part, part.TotalParts, part.Path)
+		}
+		if part.StartLine != nextLine {
+			t.Errorf("part %d starts at line %d, want %d", i+1, part.StartLine, nextLine)
+		}
+		nextLine = part.EndLine + 1
+		if i > 0 && !strings.HasPrefix(part.Contents, "// f") {
+			t.Errorf("part %d does not start at a function boundary: %q", i+1, part.Contents[:20])
+		}
+		joined.WriteString(part.Contents)
+	}
+	if joined.String() != file.Contents {
+		t.Error("the parts do not add up to the original contents")
+	}
+}
+
+func TestSplitFileLongLine(t *testing.T) {
+	file := FileInfo{Path: "app.min.js", Language: "JavaScript", Contents: strings.Repeat("var a=1;", 500)}
+	parts, err := SplitFile(file, 200)
+	if err != nil {
+		t.Fatalf("SplitFile() error = %v", err)
+	}
+	var joined strings.Builder
+	for _, part := range parts {
+		if part.StartLine != 1 || part.EndLine != 1 {
+			t.Errorf("part %d covers lines %d-%d, want 1-1", part.Part, part.StartLine, part.EndLine)
+		}
+		joined.WriteString(part.Contents)
+	}
+	if joined.String() != file.Contents {
+		t.Error("the parts do not add up to the original contents")
+	}
+}
+
+func TestSplitFileRegistryRules(t *testing.T) {
+	registry, err := NewLanguageRegistry()
+	if err != nil {
+		t.Fatalf("NewLanguageRegistry() error = %v", err)
+	}
+	// A language with comments, but without a declaration pattern, is split at comments that follow a blank line
+	if err := registry.Add(Language{Name: "Settings", Category: CategoryConfiguration, Extensions: []string{".settings"}, LineComment: ";"}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	var ruby, settings strings.Builder
+	for i := 0; i < 40; i++ {
+		fmt.Fprintf(&ruby, "# f%d prints a number\ndef f%d\n  puts %d\n  puts 'some more text'\nend\n\n", i, i, i)
+		fmt.Fprintf(&settings, "; section %d\nname%d = value\nother%d = some more text here\n\nmore%d = x\n\n", i, i, i, i)
+	}
+	testCases := []struct {
+		file   FileInfo
+		prefix string
+	}{
+		{FileInfo{Path: "lib/f.rb", Language: "Ruby", Contents: ruby.String()}, "# f"},
+		{FileInfo{Path: "app.settings", Language: "Settings", Contents: settings.String()}, "; section"},
+	}
+	for _, tc := range testCases {
+		parts, err := splitFile(tokenizer, registry, tc.file, 300)
+		if err != nil {
+			t.Fatalf("splitFile(%s) error = %v", tc.file.Path, err)
+		}
+		if len(parts) < 2 {
+			t.Fatalf("splitFile(%s) returned %d parts, want at least 2", tc.file.Path, len(parts))
+		}
+		for _, part := range parts[1:] {
+			if !strings.HasPrefix(part.Contents, tc.prefix) {
+				t.Errorf("part %d of %s does not start at a boundary: %q", part.Part, tc.file.Path, part.Contents[:20])
+			}
+		}
+	}
+}
+
+func TestDeclarationPatterns(t *testing.T) {
+	testCases := []struct {
+		language     string
+		declarations []string
+		others       []string
+	}{
+		{"Java", []string{"public class App {", "    private static int count(String s) {", "    void run() {", "public @interface Marker {", "record Point(int x, int y) {}"}, []string{"        return count(s);", "    int x = f(1);"}},
+		{"Kotlin", []string{"fun main() {", "    override fun toString(): String {", "data class Point(val x: Int)", "val answer = 42", "private const val NAME = \"x\"", "typealias Names = List<String>"}, []string{"    val local = 1", "        println(x)"}},
+		{"C#", []string{"namespace App;", "public record Point(int X, int Y);", "        public async Task<int> RunAsync(", "    internal sealed class Handler", "public delegate void Callback();"}, []string{"            return Run();", "        var x = Run();"}},
+		{"Dart", []string{"class App extends StatelessWidget {", "extension on String {", "mixin Logger {", "void main() {", "  Widget build(BuildContext context) {", "sealed class Shape {}"}, []string{"    return Text('hi');", "  if (x) {"}},
+		{"Groovy", []string{"class App {", "def run(args) {", "    static void main(String[] args) {", "trait Named {"}, []string{"        println args", "    run(args)"}},
+	}
+	for _, tc := range testCases {
+		language, ok := DefaultLanguages.Lookup(tc.language)
+		if !ok || language.declaration == nil {
+			t.Fatalf("%s has no declaration pattern", tc.language)
+		}
+		for _, line := range tc.declarations {
+			if !language.declaration.MatchString(line) {
+				t.Errorf("%s: %q is not a declaration", tc.language, line)
+			}
+		}
+		for _, line := range tc.others {
+			if language.declaration.MatchString(line) {
+				t.Errorf("%s: %q is a declaration", tc.language, line)
+			}
+		}
+	}
+}
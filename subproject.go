@@ -0,0 +1,145 @@
+package projectinfo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+)
+
+// SubProjectMode decides what happens with git submodules and other nested repositories
+type SubProjectMode int
+
+const (
+	// SubProjectsExclude skips the files of sub-projects, which are only listed in ProjectInfo.SubProjects
+	SubProjectsExclude SubProjectMode = iota
+	// SubProjectsRecurse skips the files of sub-projects, and analyzes each sub-project as a child ProjectInfo
+	SubProjectsRecurse
+	// SubProjectsInline collects the files of sub-projects as if they were part of the project
+	SubProjectsInline
+)
+
+// SubProject is a git submodule or another git repository inside of the project directory
+type SubProject struct {
+	Path      string       `json:"path"` // relative to the project directory
+	Name      string       `json:"name"`
+	URL       string       `json:"url,omitempty"`
+	Commit    string       `json:"commit,omitempty"` // the checked out commit, or the commit recorded by the parent repository if the submodule is not checked out
+	Submodule bool         `json:"submodule"`        // listed in .gitmodules, as opposed to just being a nested repository
+	Project   *ProjectInfo `json:"project,omitempty"`
+}
+
+// gitSubmodule is an entry in a .gitmodules file
+type gitSubmodule struct {
+	name string
+	url  string
+}
+
+// readGitModules reads the submodules from the .gitmodules file in the given directory, by their slash separated paths
+func readGitModules(dir string) map[string]gitSubmodule {
+	submodules := make(map[string]gitSubmodule)
+	config, err := ParseGitConfig(filepath.Join(dir, ".gitmodules"), "")
+	if err != nil {
+		return submodules
+	}
+	for _, name := range config.Subsections("submodule") {
+		if path := config.Get("submodule." + name + ".path"); path != "" {
+			submodules[filepath.ToSlash(filepath.Clean(path))] = gitSubmodule{name: name, url: stripCredentials(config.Get("submodule." + name + ".url"))}
+		}
+	}
+	return submodules
+}
+
+// isNestedRepository checks if a directory has a .git directory or file of its own
+func isNestedRepository(dir string) bool {
+	_, err := os.Lstat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// recordedSubmoduleCommit returns the commit that the HEAD commit of the repository containing dir records for a submodule at the given path
+func recordedSubmoduleCommit(dir, path string) string {
+	repo, err := openGitRepository(dir)
+	if err != nil {
+		return ""
+	}
+	defer repo.Close()
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(repo.workTree, abs)
+	if err != nil {
+		return ""
+	}
+	head, err := repo.readRef("HEAD")
+	if err != nil {
+		return ""
+	}
+	commit, err := repo.readCommit(head)
+	if err != nil {
+		return ""
+	}
+	if h, ok := repo.blobAt(commit.tree, filepath.ToSlash(rel)); ok {
+		return h.String()
+	}
+	return ""
+}
+
+// readSubProjects describes the sub-projects that were found in the given directory, and analyzes them if opts.SubProjects is SubProjectsRecurse
+func readSubProjects(ctx context.Context, dir string, subDirs []string, submodules map[string]gitSubmodule, opts Options) ([]SubProject, error) {
+	var subProjects []SubProject
+	for _, subDir := range subDirs {
+		rel, err := filepath.Rel(dir, subDir)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		submodule, isSubmodule := submodules[rel]
+		subProject := SubProject{Path: rel, Name: submodule.name, URL: submodule.url, Submodule: isSubmodule}
+		if name, err := ReadProjectName(subDir); err == nil {
+			subProject.Name = name
+		} else if subProject.Name == "" {
+			subProject.Name = filepath.Base(subDir)
+		}
+		if isNestedRepository(subDir) {
+			if info, err := ReadGitRepoInfo(subDir); err == nil {
+				subProject.Commit = info.HeadCommit
+				if subProject.URL == "" && len(info.Remotes) > 0 && len(info.Remotes[0].URLs) > 0 {
+					subProject.URL = info.Remotes[0].URLs[0]
+				}
+			}
+		}
+		if subProject.Commit == "" && isSubmodule {
+			subProject.Commit = recordedSubmoduleCommit(dir, subDir)
+		}
+		if opts.SubProjects == SubProjectsRecurse && isNestedRepository(subDir) {
+			child, err := NewWithContext(ctx, subDir, opts)
+			if err != nil {
+				return nil, err
+			}
+			subProject.Project = &child
+		}
+		subProjects = append(subProjects, subProject)
+	}
+	return subProjects, nil
+}
+
+// permalinkRoots returns the repositories that the files of the project in the given directory can be linked to.
+// The files of inlined sub-projects are linked to the URL and commit of the sub-project, not to those of the project.
+func permalinkRoots(dir string, gitInfo GitRepoInfo, repository *RepoURLInfo, subProjects []SubProject) []permalinkRoot {
+	var roots []permalinkRoot
+	if gitInfo.WorkTree != "" {
+		roots = append(roots, permalinkRoot{dir: gitInfo.WorkTree, repo: repository, commit: gitInfo.HeadCommit})
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return roots
+	}
+	for _, subProject := range subProjects {
+		root := permalinkRoot{dir: filepath.Join(absDir, filepath.FromSlash(subProject.Path)), commit: subProject.Commit}
+		if info, err := ParseRepoURL(subProject.URL); err == nil {
+			root.repo = &info
+		}
+		roots = append(roots, root)
+	}
+	return roots
+}
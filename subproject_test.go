@@ -0,0 +1,89 @@
+package projectinfo
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSubProjects(t *testing.T) {
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	// A nested repository with a commit of its own
+	nested := newTestGitRepo(t)
+	nestedCommit := nested.commit(nested.tree(map[string]gitHash{"go.mod": nested.blob("module example.com/nested\n"), "nested.go": nested.blob("package nested\n")}), "Nina", 1000)
+	nested.setRef("refs/heads/main", nestedCommit)
+	nested.writeFile(".git/config", "[remote \"origin\"]\n\turl = https://example.com/nested.git\n")
+	nested.writeFile("go.mod", "module example.com/nested\n")
+	nested.writeFile("nested.go", "package nested\n")
+
+	// The parent repository, with a submodule that is not checked out, and the nested repository
+	parent := newTestGitRepo(t)
+	var recorded gitHash
+	recorded[0] = 0xab
+	modules := "[submodule \"libfoo\"]\n\tpath = lib\n\turl = https://example.com/libfoo.git\n"
+	parentCommit := parent.commit(parent.tree(map[string]gitHash{"a.go": parent.blob("package a\n"), ".gitmodules": parent.blob(modules), "lib@": recorded}), "Alice", 1000)
+	parent.setRef("refs/heads/main", parentCommit)
+	parent.writeFile("a.go", "package a\n")
+	parent.writeFile(".gitmodules", modules)
+	parent.writeFile(".git/config", "[remote \"origin\"]\n\turl = git@github.com:example/parent.git\n")
+	if err := os.MkdirAll(filepath.Join(parent.dir, "lib"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.Rename(nested.dir, filepath.Join(parent.dir, "nested")); err != nil {
+		t.Fatalf("Failed to move the nested repository: %v", err)
+	}
+
+	testCases := []struct {
+		mode      SubProjectMode
+		wantFiles []string
+	}{
+		{SubProjectsExclude, []string{"a.go"}},
+		{SubProjectsRecurse, []string{"a.go"}},
+		{SubProjectsInline, []string{"a.go", "nested/nested.go"}},
+	}
+	for _, tc := range testCases {
+		project, err := NewWithOptions(parent.dir, Options{SubProjects: tc.mode, NoAPIServerCheck: true})
+		if err != nil {
+			t.Fatalf("NewWithOptions() error = %v", err)
+		}
+		var files []string
+		for _, file := range project.SourceFiles {
+			rel, _ := filepath.Rel(parent.dir, file.Path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		if !reflect.DeepEqual(files, tc.wantFiles) {
+			t.Errorf("source files with mode %d = %v, want %v", tc.mode, files, tc.wantFiles)
+		}
+		// The files of an inlined sub-project are linked to the repository and commit of the sub-project
+		wantLinks := map[string]string{
+			"a.go":             "https://github.com/example/parent/blob/" + parentCommit.String() + "/a.go",
+			"nested/nested.go": "https://example.com/nested/blob/" + nestedCommit.String() + "/nested.go",
+		}
+		for i, file := range project.SourceFiles {
+			if want := wantLinks[files[i]]; file.Permalink != want {
+				t.Errorf("permalink of %s with mode %d = %q, want %q", files[i], tc.mode, file.Permalink, want)
+			}
+		}
+
+		if len(project.SubProjects) != 2 {
+			t.Fatalf("SubProjects with mode %d = %+v, want two", tc.mode, project.SubProjects)
+		}
+		lib, nestedProject := project.SubProjects[0], project.SubProjects[1]
+		wantLib := SubProject{Path: "lib", Name: "libfoo", URL: "https://example.com/libfoo.git", Commit: recorded.String(), Submodule: true}
+		if !reflect.DeepEqual(lib, wantLib) {
+			t.Errorf("submodule = %+v, want %+v", lib, wantLib)
+		}
+		if nestedProject.Path != "nested" || nestedProject.Name != "example.com/nested" || nestedProject.URL != "https://example.com/nested.git" ||
+			nestedProject.Commit != nestedCommit.String() || nestedProject.Submodule {
+			t.Errorf("nested repository = %+v", nestedProject)
+		}
+		if (nestedProject.Project != nil) != (tc.mode == SubProjectsRecurse) {
+			t.Errorf("nested repository with mode %d has the child project %+v", tc.mode, nestedProject.Project)
+		}
+		if nestedProject.Project != nil && (len(nestedProject.Project.SourceFiles) != 1 || nestedProject.Project.Contributors != "Nina") {
+			t.Errorf("child project = %+v, want nested.go by Nina", nestedProject.Project)
+		}
+	}
+}
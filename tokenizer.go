@@ -0,0 +1,34 @@
+package projectinfo
+
+import "unicode/utf8"
+
+// Tokenizer counts the number of tokens a language model would use for a string
+type Tokenizer interface {
+	CountTokens(input string) int
+}
+
+// HeuristicTokenizer estimates the number of tokens as one token per four UTF-8 runes.
+// It is fast and needs no vocabulary, but is inaccurate for code with much punctuation and for CJK text.
+type HeuristicTokenizer struct{}
+
+// CountTokens estimates the number of tokens in a string based on UTF-8 rune count
+func (HeuristicTokenizer) CountTokens(input string) int {
+	runeCount := utf8.RuneCountInString(input)
+	return (runeCount + 3) / 4 // A rough estimate of token count, suitable for simple use cases
+}
+
+var tokenizer Tokenizer = HeuristicTokenizer{}
+
+// SetTokenizer sets the tokenizer that is used by CountTokens, and thereby for token counts and chunk budgets.
+// Passing nil restores the default HeuristicTokenizer.
+func SetTokenizer(t Tokenizer) {
+	if t == nil {
+		t = HeuristicTokenizer{}
+	}
+	tokenizer = t
+}
+
+// CurrentTokenizer returns the tokenizer that is used by CountTokens
+func CurrentTokenizer() Tokenizer {
+	return tokenizer
+}